@@ -0,0 +1,80 @@
+package actuator
+
+// Actuator is a single joint, driven by a servo or whatever else. Angles are in
+// degrees, with the calibration (if any) already applied, so zero is wherever
+// the kinematics expects it to be.
+type Actuator interface {
+
+	// ID returns the ID of the actuator, which is unique within the robot.
+	ID() int
+
+	// SetGoal sets the angle to move to. This may be buffered until the end of
+	// the tick, so that all of the actuators start moving together.
+	SetGoal(angle float64) error
+
+	// SetSpeed sets the speed (in degrees per second) to move towards the goal
+	// at. Zero is as fast as possible.
+	SetSpeed(speed float64) error
+
+	// SetTorque sets the maximum torque, as a fraction of the most which the
+	// actuator can provide. Zero disables it.
+	SetTorque(torque float64) error
+
+	// Angle returns the present angle.
+	Angle() (float64, error)
+
+	// Load returns the present load, as a fraction of the maximum torque.
+	// Positive is counter-clockwise.
+	Load() (float64, error)
+
+	// Temperature returns the internal temperature, in degrees celsius.
+	Temperature() (float64, error)
+
+	// Voltage returns the supply voltage, in volts.
+	Voltage() (float64, error)
+}
+
+// Factory returns the actuator with the given ID.
+type Factory func(ID int) (Actuator, error)
+
+// Compliance is how stiffly an actuator holds its goal. Not every actuator can
+// be this fussy; see Compliant.
+type Compliance struct {
+
+	// The error (in degrees) which is tolerated either side of the goal.
+	Margin float64 `json:"margin"`
+
+	// How far (in degrees) beyond the margin the torque ramps up over.
+	Slope float64 `json:"slope"`
+
+	// The minimum torque used to move towards the goal, as a fraction of the
+	// maximum.
+	Punch float64 `json:"punch"`
+
+	// The maximum torque, as a fraction. See SetTorque.
+	Torque float64 `json:"torque"`
+
+	// The maximum speed, in degrees per second. Zero is as fast as possible.
+	Speed float64 `json:"speed"`
+}
+
+// Compliant is implemented by actuators which can have their compliance set.
+// Those which can't just get the torque and speed.
+type Compliant interface {
+	SetCompliance(c Compliance) error
+}
+
+// SetCompliance sets the compliance of the given actuator, or just its torque
+// and speed if it can't do any better.
+func SetCompliance(a Actuator, c Compliance) error {
+	if ca, ok := a.(Compliant); ok {
+		return ca.SetCompliance(c)
+	}
+
+	err := a.SetTorque(c.Torque)
+	if err != nil {
+		return err
+	}
+
+	return a.SetSpeed(c.Speed)
+}
@@ -0,0 +1,145 @@
+package actuator
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+const (
+
+	// The speed (in degrees per second) which simulated actuators move at when
+	// told to move as fast as possible. About the same as an AX-12.
+	simMaxSpeed = 684.0
+
+	simTemperature = 25.0
+	simVoltage     = 12.0
+)
+
+// Sim is a simulated actuator, which moves towards its goal at its speed, and
+// never gets tired. It's for running without any hardware.
+type Sim struct {
+	mu sync.Mutex
+	id int
+
+	// The angle at the time of the last change, and the goal.
+	from  float64
+	at    time.Time
+	goal  float64
+	speed float64
+
+	torque float64
+
+	// The time source. This is only replaced by tests.
+	clock func() time.Time
+}
+
+// NewSim returns a simulated actuator with the given ID, at zero degrees.
+func NewSim(ID int) *Sim {
+	return &Sim{
+		id:     ID,
+		torque: 1,
+		clock:  time.Now,
+	}
+}
+
+// SimFactory is a Factory which returns simulated actuators.
+func SimFactory(ID int) (Actuator, error) {
+	return NewSim(ID), nil
+}
+
+func (s *Sim) ID() int {
+	return s.id
+}
+
+// angle returns the present angle. The caller must hold the lock.
+func (s *Sim) angle() float64 {
+
+	// Actuators with no torque don't go anywhere.
+	if s.torque == 0 {
+		return s.from
+	}
+
+	speed := s.speed
+	if speed == 0 {
+		speed = simMaxSpeed
+	}
+
+	d := s.goal - s.from
+	max := speed * s.clock().Sub(s.at).Seconds()
+	if math.Abs(d) <= max {
+		return s.goal
+	}
+
+	return s.from + math.Copysign(max, d)
+}
+
+// update moves the starting point to the present angle, before changing how
+// it moves. The caller must hold the lock.
+func (s *Sim) update() {
+	s.from = s.angle()
+	s.at = s.clock()
+}
+
+func (s *Sim) SetGoal(angle float64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.update()
+	s.goal = angle
+	return nil
+}
+
+func (s *Sim) SetSpeed(speed float64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.update()
+	s.speed = speed
+	return nil
+}
+
+func (s *Sim) SetTorque(torque float64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.update()
+	s.torque = torque
+	return nil
+}
+
+func (s *Sim) Angle() (float64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.angle(), nil
+}
+
+func (s *Sim) Load() (float64, error) {
+	return 0, nil
+}
+
+func (s *Sim) Temperature() (float64, error) {
+	return simTemperature, nil
+}
+
+func (s *Sim) Voltage() (float64, error) {
+	return simVoltage, nil
+}
+
+// Goal returns the goal angle.
+func (s *Sim) Goal() float64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.goal
+}
+
+// Speed returns the speed which the actuator was last set to.
+func (s *Sim) Speed() float64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.speed
+}
+
+// Torque returns the torque which the actuator was last set to.
+func (s *Sim) Torque() float64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.torque
+}
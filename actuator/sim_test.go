@@ -0,0 +1,51 @@
+package actuator
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSim(t *testing.T) {
+	now := time.Unix(0, 0)
+	s := NewSim(1)
+	s.clock = func() time.Time { return now }
+
+	assert.Equal(t, 1, s.ID())
+
+	// Moves at the given speed.
+	s.SetSpeed(100)
+	s.SetGoal(30)
+	now = now.Add(100 * time.Millisecond)
+	a, err := s.Angle()
+	assert.NoError(t, err)
+	assert.InDelta(t, 10, a, 1e-9)
+
+	// Stops at the goal.
+	now = now.Add(time.Second)
+	a, _ = s.Angle()
+	assert.InDelta(t, 30, a, 1e-9)
+
+	// Changing speed mid-move starts from wherever it got to.
+	s.SetGoal(0)
+	now = now.Add(100 * time.Millisecond)
+	s.SetSpeed(50)
+	now = now.Add(100 * time.Millisecond)
+	a, _ = s.Angle()
+	assert.InDelta(t, 15, a, 1e-9)
+
+	// Doesn't move without torque.
+	s.SetTorque(0)
+	now = now.Add(time.Second)
+	a, _ = s.Angle()
+	assert.InDelta(t, 15, a, 1e-9)
+}
+
+func TestSetCompliance(t *testing.T) {
+	s := NewSim(1)
+	err := SetCompliance(s, Compliance{Margin: 1, Torque: 0.5, Speed: 200})
+	assert.NoError(t, err)
+	assert.Equal(t, 0.5, s.Torque())
+	assert.Equal(t, 200.0, s.Speed())
+}
@@ -0,0 +1,497 @@
+// Command calibrate finds the zero offset of each servo, by moving the legs to
+// a known pose (with the hex sitting on a jig, feet in the air), and letting the
+// user jog each joint until it lines up. The offsets are written to the same
+// calibration file which the main program reads at startup.
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/adammck/dynamixel/network"
+	"github.com/adammck/hexapod"
+	"github.com/adammck/hexapod/actuator"
+	"github.com/adammck/hexapod/components/controller"
+	"github.com/adammck/hexapod/components/legs"
+	fake_serial "github.com/adammck/hexapod/fake/serial"
+	"github.com/adammck/hexapod/servos"
+	"github.com/adammck/sixaxis"
+	"github.com/jacobsa/go-serial/serial"
+)
+
+var (
+	serialPort     = flag.String("serial-port", "/dev/ttyACM0", "path to the serial port, or a list of name=path for several buses (e.g. left=/dev/ttyACM0,right=/dev/ttyACM1)")
+	controllerPort = flag.String("controller-port", "", "path to the sixaxis controller (default: use the keyboard)")
+	calFile        = flag.String("calibration", "calibration.json", "path to the servo calibration file")
+	robotFile      = flag.String("robot", "robot.json", "path to the robot description, which declares the model of each servo")
+	debug          = flag.Bool("debug", false, "enable verbose logging")
+	offline        = flag.Bool("offline", false, "run in offline mode (with a fake serial port)")
+)
+
+const (
+
+	// How far (in degrees) to jog a joint per press. Fine jogs are for the
+	// last little bit, once the joint is nearly lined up.
+	jogStep     = 1.0
+	jogStepFine = 0.1
+
+	// Keep the servos slow (in degrees per second) and weak, since the user will
+	// have their fingers in the jig.
+	moveSpeed = 85.0
+	torque    = 0.5
+
+	// How long to wait for the feet to reach their home positions before
+	// reading them back during verification.
+	verifyWait = 2 * time.Second
+
+	// Minimum pressure needed to trigger a button press.
+	minButtonPressure = 10
+)
+
+// The angle of every joint in the jig pose: each leg sticking straight out
+// horizontally from the body, in the direction which it points. This is easy
+// to line up against a straight edge.
+var jigAngles = legs.Angles{Coxa: 0, Femur: 0, Tibia: 0, Tarsus: 0}
+
+var jointNames = [4]string{"coxa", "femur", "tibia", "tarsus"}
+
+type command int
+
+const (
+	cmdJogUp command = iota
+	cmdJogDown
+	cmdJogUpFine
+	cmdJogDownFine
+	cmdNext
+	cmdPrev
+	cmdReverse
+	cmdVerify
+	cmdWrite
+	cmdQuit
+)
+
+const usage = `Line each joint up with the jig, one at a time:
+
+  +/-   jog the joint by 1 degree      (sixaxis: up/down)
+  ./,   jog the joint by 0.1 degrees   (sixaxis: R1 + up/down)
+  n/p   select the next/previous joint (sixaxis: right/left)
+  r     reverse the joint's direction  (sixaxis: select + square)
+  v     verify the home foot positions (sixaxis: triangle)
+  w     write the calibration file     (sixaxis: cross)
+  q     quit                           (sixaxis: start)
+
+Keys are read a line at a time, so press enter after them. Several keys may be
+given at once, e.g. "+++" jogs by 3 degrees.
+`
+
+type calibrator struct {
+	h    *hexapod.Hexapod
+	legs *legs.Legs
+
+	// The index of the selected leg and joint.
+	leg   int
+	joint int
+}
+
+func main() {
+	flag.Parse()
+	var err error
+
+	if *debug {
+		log.SetLevel(log.DebugLevel)
+	}
+
+	ports, err := servos.ParsePorts(*serialPort)
+	if err != nil {
+		log.Fatalf("error parsing serial ports: %s", err)
+	}
+
+	var buses servos.Buses
+	for _, port := range ports {
+		var srl io.ReadWriteCloser
+		if *offline {
+			log.Warnf("using fake serial port for %s bus", port.Name)
+			srl = &fake_serial.FakeSerial{}
+
+		} else {
+			log.Infof("opening serial port for %s bus: %s", port.Name, port.Path)
+			srl, err = serial.Open(serial.OpenOptions{
+				PortName:              port.Path,
+				BaudRate:              1000000,
+				DataBits:              8,
+				StopBits:              1,
+				MinimumReadSize:       0,
+				InterCharacterTimeout: 100,
+			})
+			if err != nil {
+				log.Fatalf("error opening serial port: %s\n", err)
+			}
+			defer srl.Close()
+
+			_, err = ioutil.ReadAll(srl)
+			if err != nil {
+				log.Fatalf("error purging serial buffer: %s\n", err)
+			}
+		}
+
+		n := network.New(srl)
+		n.Timeout = 1 * time.Second
+		buses = append(buses, servos.NewBus(port.Name, n))
+	}
+
+	h := hexapod.NewHexapod(buses, 0)
+
+	err = h.Pool.LoadDescription(*robotFile)
+	if err != nil && !os.IsNotExist(err) {
+		log.Errorf("error loading robot description: %s", err)
+		return
+	}
+
+	// Start from the existing calibration, if there is one, so each servo only
+	// needs touching up.
+	err = h.Pool.LoadCalibration(*calFile)
+	if err != nil {
+		log.Warnf("error loading calibration: %s (starting from scratch)", err)
+	}
+
+	c := &calibrator{
+		h:    h,
+		legs: legs.New(h.Pool.Actuator),
+	}
+	defer h.Pool.Shutdown()
+
+	for _, leg := range c.legs.Legs {
+		if leg.Disabled != "" {
+			log.Warnf("skipping %s leg: %s", leg.Name, leg.Disabled)
+		}
+	}
+
+	err = c.boot()
+	if err != nil {
+		log.Errorf("error while booting: %s", err)
+		return
+	}
+
+	var cmds <-chan command
+	if *controllerPort == "" {
+		cmds = readKeyboard(os.Stdin)
+	} else {
+		f, err := os.Open(*controllerPort)
+		if err != nil {
+			log.Errorf("error opening controller: %s", err)
+			return
+		}
+		defer f.Close()
+		cmds = readController(f)
+	}
+
+	fmt.Print(usage)
+	c.selectJoint(0)
+
+	for cmd := range cmds {
+		err = c.run(cmd)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			log.Error(err)
+		}
+	}
+
+	log.Info("done")
+}
+
+// boot configures every servo, and moves the legs to the jig pose.
+func (c *calibrator) boot() error {
+	for _, a := range c.legs.Actuators() {
+		err := a.SetSpeed(moveSpeed)
+		if err != nil {
+			return fmt.Errorf("%s (while setting move speed of #%d)", err, a.ID())
+		}
+
+		err = a.SetTorque(torque)
+		if err != nil {
+			return fmt.Errorf("%s (while setting torque limit of #%d)", err, a.ID())
+		}
+	}
+
+	return c.moveToJig()
+}
+
+func (c *calibrator) moveToJig() error {
+	for _, leg := range c.legs.Legs {
+		if leg.Disabled != "" {
+			continue
+		}
+
+		err := leg.SetAngles(jigAngles)
+		if err != nil {
+			return fmt.Errorf("%s (while moving %s leg to jig pose)", err, leg.Name)
+		}
+	}
+
+	return c.h.ActionInstruction()
+}
+
+func (c *calibrator) run(cmd command) error {
+	switch cmd {
+	case cmdJogUp:
+		return c.jog(jogStep)
+	case cmdJogDown:
+		return c.jog(-jogStep)
+	case cmdJogUpFine:
+		return c.jog(jogStepFine)
+	case cmdJogDownFine:
+		return c.jog(-jogStepFine)
+	case cmdNext:
+		c.selectJoint(1)
+	case cmdPrev:
+		c.selectJoint(-1)
+	case cmdReverse:
+		return c.reverse()
+	case cmdVerify:
+		return c.verify()
+	case cmdWrite:
+		err := c.h.Pool.SaveCalibration(*calFile)
+		if err != nil {
+			return fmt.Errorf("%s (while writing calibration)", err)
+		}
+		log.Infof("wrote calibration to %s", *calFile)
+	case cmdQuit:
+		return io.EOF
+	}
+
+	return nil
+}
+
+// servo returns the actuator driving the selected joint.
+func (c *calibrator) servo() actuator.Actuator {
+	leg := c.legs.Legs[c.leg]
+	return [4]actuator.Actuator{leg.Coxa, leg.Femur, leg.Tibia, leg.Tarsus}[c.joint]
+}
+
+// setLED turns the LED of the given actuator on or off, if it has one.
+func setLED(a actuator.Actuator, state bool) {
+	if l, ok := a.(interface {
+		SetLED(bool) error
+	}); ok {
+		l.SetLED(state)
+	}
+}
+
+// selectJoint moves the selection forwards or backwards by the given number of
+// joints, skipping any which are missing, and flashes the LED of the new one.
+func (c *calibrator) selectJoint(d int) {
+	if s := c.servo(); s != nil {
+		setLED(s, false)
+	}
+
+	n := len(c.legs.Legs) * len(jointNames)
+	i := c.leg*len(jointNames) + c.joint
+
+	for tries := 0; tries < n; tries++ {
+		i = ((i+d)%n + n) % n
+		c.leg, c.joint = i/len(jointNames), i%len(jointNames)
+
+		if c.servo() != nil {
+			break
+		}
+
+		// Skip over missing servos in the same direction, or forwards if the
+		// selection wasn't moving.
+		if d == 0 {
+			d = 1
+		}
+	}
+
+	s := c.servo()
+	if s == nil {
+		log.Warn("no servos available")
+		return
+	}
+
+	setLED(s, true)
+	log.Infof("selected %s %s (#%d): %+v", c.legs.Legs[c.leg].Name, jointNames[c.joint], s.ID(), c.h.Pool.GetCalibration(s.ID()))
+}
+
+// jog moves the selected joint by the given angle, by adjusting its offset, and
+// moving it back to the jig pose.
+func (c *calibrator) jog(d float64) error {
+	s := c.servo()
+	if s == nil {
+		return nil
+	}
+
+	cal := c.h.Pool.GetCalibration(s.ID())
+	cal.Offset += d
+	c.h.Pool.SetCalibration(s.ID(), cal)
+	log.Infof("#%d offset=%+.1f", s.ID(), cal.Offset)
+
+	return c.moveToJig()
+}
+
+// reverse flips the direction of the selected joint. This is only necessary if
+// a servo has been mounted backwards, which should be obvious during the
+// verification step.
+func (c *calibrator) reverse() error {
+	s := c.servo()
+	if s == nil {
+		return nil
+	}
+
+	cal := c.h.Pool.GetCalibration(s.ID())
+	cal.Reversed = !cal.Reversed
+	c.h.Pool.SetCalibration(s.ID(), cal)
+	log.Infof("#%d reversed=%v", s.ID(), cal.Reversed)
+
+	return c.moveToJig()
+}
+
+// verify moves each foot to its home position, and reports where the feet
+// actually ended up, according to the (calibrated) servo positions. Compare
+// these to the actual feet with a ruler.
+func (c *calibrator) verify() error {
+	home := c.legs.HomeFootPositions()
+
+	for i, leg := range c.legs.Legs {
+		if leg.Disabled != "" {
+			continue
+		}
+
+		err := leg.SetGoal(home[i])
+		if err != nil {
+			return fmt.Errorf("%s (while moving %s leg home)", err, leg.Name)
+		}
+	}
+
+	err := c.h.ActionInstruction()
+	if err != nil {
+		return err
+	}
+
+	time.Sleep(verifyWait)
+
+	var total float64
+	for i, leg := range c.legs.Legs {
+		if leg.Disabled != "" {
+			continue
+		}
+
+		v, err := leg.PresentPosition()
+		if err != nil {
+			log.Warn(err)
+			continue
+		}
+
+		d := v.Distance(home[i])
+		total += d
+		log.Infof("%s: present=%v, home=%v, distance=%+07.2f", leg.Name, v, home[i], d)
+	}
+
+	log.Infof("total distance from home: %+07.2f", total)
+
+	// Go back to the jig pose, to carry on calibrating.
+	return c.moveToJig()
+}
+
+// readKeyboard returns a channel of the commands typed into the given reader,
+// which is closed at EOF.
+func readKeyboard(r io.Reader) <-chan command {
+	keys := map[rune]command{
+		'+': cmdJogUp,
+		'=': cmdJogUp,
+		'-': cmdJogDown,
+		'.': cmdJogUpFine,
+		',': cmdJogDownFine,
+		'n': cmdNext,
+		'p': cmdPrev,
+		'r': cmdReverse,
+		'v': cmdVerify,
+		'w': cmdWrite,
+		'q': cmdQuit,
+	}
+
+	ch := make(chan command)
+	go func() {
+		defer close(ch)
+		br := bufio.NewReader(r)
+
+		for {
+			k, _, err := br.ReadRune()
+			if err != nil {
+				return
+			}
+
+			if cmd, ok := keys[k]; ok {
+				ch <- cmd
+			}
+		}
+	}()
+
+	return ch
+}
+
+// readController returns a channel of the commands pressed on the Sixaxis
+// controller read from the given reader. It's never closed; press start to
+// quit.
+func readController(r io.Reader) <-chan command {
+	sa := sixaxis.New(r)
+	go sa.Run()
+
+	var up, down, left, right, square, triangle, cross, start controller.Latch
+
+	ch := make(chan command)
+	go func() {
+		for range time.Tick(time.Second / 60) {
+			fine := sa.R1 > minButtonPressure
+
+			if up.Run(sa.Up > minButtonPressure) {
+				if fine {
+					ch <- cmdJogUpFine
+				} else {
+					ch <- cmdJogUp
+				}
+			}
+
+			if down.Run(sa.Down > minButtonPressure) {
+				if fine {
+					ch <- cmdJogDownFine
+				} else {
+					ch <- cmdJogDown
+				}
+			}
+
+			if right.Run(sa.Right > minButtonPressure) {
+				ch <- cmdNext
+			}
+
+			if left.Run(sa.Left > minButtonPressure) {
+				ch <- cmdPrev
+			}
+
+			if square.Run(sa.Select && sa.Square > minButtonPressure) {
+				ch <- cmdReverse
+			}
+
+			if triangle.Run(sa.Triangle > minButtonPressure) {
+				ch <- cmdVerify
+			}
+
+			if cross.Run(sa.Cross > minButtonPressure) {
+				ch <- cmdWrite
+			}
+
+			if start.Run(sa.Start) {
+				ch <- cmdQuit
+			}
+		}
+	}()
+
+	return ch
+}
@@ -0,0 +1,170 @@
+package animation
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+
+	"github.com/adammck/hexapod/math3d"
+)
+
+// The names of the legs, in the order used by the legs component.
+var legNames = [6]string{"FL", "FR", "MR", "BR", "BL", "ML"}
+
+// Animation is a timeline of keyframes. The hex eases from wherever it was
+// standing through each keyframe in turn, then back to where it started.
+type Animation struct {
+	Name      string     `json:"name"`
+	Keyframes []Keyframe `json:"keyframes"`
+
+	// The time (in seconds) to take to return to the starting position after
+	// the last keyframe. Defaults to one second.
+	Return float64 `json:"return"`
+}
+
+// Keyframe is a single point in an animation. Anything which isn't specified
+// stays as it was in the previous keyframe.
+type Keyframe struct {
+
+	// The time (in seconds) to take to get here from the previous keyframe.
+	Duration float64 `json:"duration"`
+
+	// How to get here from the previous keyframe.
+	Easing Easing `json:"easing"`
+
+	// The pose of the body, relative to its pose at the start.
+	Body *Body `json:"body"`
+
+	// The position of any number of feet.
+	Feet []Foot `json:"feet"`
+
+	// The point (in the hexapod space at the start) to aim the head at.
+	LookAt *Point `json:"lookAt"`
+}
+
+// Body is the position (in mm) and orientation (in degrees) of the body.
+type Body struct {
+	X       float64 `json:"x"`
+	Y       float64 `json:"y"`
+	Z       float64 `json:"z"`
+	Heading float64 `json:"heading"`
+	Pitch   float64 `json:"pitch"`
+	Bank    float64 `json:"bank"`
+}
+
+func (b Body) pose() math3d.Pose {
+	return math3d.Pose{
+		Position: math3d.Vector3{X: b.X, Y: b.Y, Z: b.Z},
+		Heading:  b.Heading,
+		Pitch:    b.Pitch,
+		Bank:     b.Bank,
+	}
+}
+
+// Point is a position (in mm).
+type Point struct {
+	X float64 `json:"x"`
+	Y float64 `json:"y"`
+	Z float64 `json:"z"`
+}
+
+func (p Point) vector() math3d.Vector3 {
+	return math3d.Vector3{X: p.X, Y: p.Y, Z: p.Z}
+}
+
+// Foot is the position (in mm) of a single foot. In the "leg" space, the origin
+// is at the base of the leg (wherever the body is in the same keyframe), and Z
+// points along it. In the "body" space (the default), the origin is the center
+// of the body at the start of the animation, so feet stay put on the ground
+// while the body moves.
+type Foot struct {
+	Leg   string `json:"leg"`
+	Space string `json:"space"`
+	Point
+}
+
+// Validate returns an error if the animation is unusable.
+func (a Animation) Validate() error {
+	if a.Name == "" {
+		return fmt.Errorf("animation has no name")
+	}
+
+	if len(a.Keyframes) == 0 {
+		return fmt.Errorf("animation %q has no keyframes", a.Name)
+	}
+
+	if a.Return < 0 {
+		return fmt.Errorf("animation %q: return must not be negative, was %0.2f", a.Name, a.Return)
+	}
+
+	for i, k := range a.Keyframes {
+		if k.Duration <= 0 {
+			return fmt.Errorf("animation %q: keyframe %d duration must be positive, was %0.2f", a.Name, i, k.Duration)
+		}
+
+		if !k.Easing.valid() {
+			return fmt.Errorf("animation %q: keyframe %d has unknown easing: %q", a.Name, i, k.Easing)
+		}
+
+		for _, f := range k.Feet {
+			if legIndex(f.Leg) < 0 {
+				return fmt.Errorf("animation %q: keyframe %d has unknown leg: %q", a.Name, i, f.Leg)
+			}
+
+			if f.Space != "" && f.Space != "body" && f.Space != "leg" {
+				return fmt.Errorf("animation %q: keyframe %d has unknown space: %q", a.Name, i, f.Space)
+			}
+		}
+	}
+
+	return nil
+}
+
+func legIndex(name string) int {
+	for i, n := range legNames {
+		if n == name {
+			return i
+		}
+	}
+
+	return -1
+}
+
+// LoadFile returns the animation defined in the given JSON file.
+func LoadFile(path string) (Animation, error) {
+	var a Animation
+
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return a, err
+	}
+
+	err = json.Unmarshal(b, &a)
+	if err != nil {
+		return a, fmt.Errorf("%s (while parsing %s)", err, path)
+	}
+
+	return a, a.Validate()
+}
+
+// LoadDir returns the animations defined in every JSON file in the given
+// directory.
+func LoadDir(dir string) ([]Animation, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+
+	aa := make([]Animation, 0, len(paths))
+	for _, p := range paths {
+		a, err := LoadFile(p)
+		if err != nil {
+			return nil, err
+		}
+
+		aa = append(aa, a)
+	}
+
+	return aa, nil
+}
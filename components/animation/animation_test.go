@@ -0,0 +1,167 @@
+package animation
+
+import (
+	"testing"
+	"time"
+
+	"github.com/adammck/hexapod"
+	"github.com/adammck/hexapod/math3d"
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeLegs struct {
+	feet [6]math3d.Vector3
+}
+
+func (f *fakeLegs) FootPositions() [6]math3d.Vector3 {
+	return f.feet
+}
+
+func (f *fakeLegs) LegMatrices() [6]math3d.Matrix44 {
+	var m [6]math3d.Matrix44
+	for i := range m {
+		m[i] = *math3d.MakeMatrix44(math3d.Vector3{X: float64(i)}, math3d.EulerAngles{})
+	}
+	return m
+}
+
+var testAnimation = Animation{
+	Name: "test",
+	Keyframes: []Keyframe{
+		{Duration: 1, Body: &Body{Y: 10}},
+		{Duration: 1, Easing: EaseInOut, Feet: []Foot{{Leg: "FR", Space: "leg", Point: Point{Y: 50}}}},
+		{Duration: 1, Body: &Body{}},
+	},
+	Return: 2,
+}
+
+func TestEasing(t *testing.T) {
+	for _, e := range []Easing{"", Linear, EaseIn, EaseOut, EaseInOut} {
+		assert.Equal(t, 0.0, e.apply(0), e)
+		assert.Equal(t, 1.0, e.apply(1), e)
+	}
+
+	assert.Equal(t, 0.5, Linear.apply(0.5))
+	assert.Equal(t, 0.5, EaseInOut.apply(0.5))
+	assert.True(t, EaseIn.apply(0.5) < 0.5)
+	assert.True(t, EaseOut.apply(0.5) > 0.5)
+}
+
+func TestValidate(t *testing.T) {
+	assert.NoError(t, testAnimation.Validate())
+
+	for _, a := range []Animation{
+		{},
+		{Name: "x"},
+		{Name: "x", Keyframes: []Keyframe{{}}},
+		{Name: "x", Keyframes: []Keyframe{{Duration: 1, Easing: "bouncy"}}},
+		{Name: "x", Keyframes: []Keyframe{{Duration: 1, Feet: []Foot{{Leg: "XX"}}}}},
+		{Name: "x", Keyframes: []Keyframe{{Duration: 1, Feet: []Foot{{Leg: "FL", Space: "world"}}}}},
+	} {
+		assert.Error(t, a.Validate(), "%+v", a)
+	}
+}
+
+func TestTimeline(t *testing.T) {
+	start := pose{lookAt: defaultLookAt}
+	for i := range start.feet {
+		start.feet[i] = math3d.Vector3{X: 100, Z: float64(i)}
+	}
+
+	tl := makeTimeline(testAnimation, start, (&fakeLegs{}).LegMatrices())
+	assert.Equal(t, 5, len(tl))
+
+	p, done := tl.at(0)
+	assert.False(t, done)
+	assert.Equal(t, start, p)
+
+	// Halfway through the first keyframe (which is linear).
+	p, _ = tl.at(0.5)
+	assert.InDelta(t, 5, p.body.Position.Y, 1e-9)
+	assert.Equal(t, start.feet, p.feet)
+
+	// The body stays put while the foot moves. The foot is in the leg space,
+	// relative to the body in the same keyframe.
+	p, _ = tl.at(2)
+	assert.InDelta(t, 10, p.body.Position.Y, 1e-9)
+	assert.InDelta(t, 1, p.feet[1].X, 1e-9)
+	assert.InDelta(t, 60, p.feet[1].Y, 1e-9)
+	assert.Equal(t, start.feet[0], p.feet[0])
+
+	// Then everything goes back to the start.
+	p, done = tl.at(5)
+	assert.True(t, done)
+	assert.Equal(t, start, p)
+}
+
+func TestAnimator(t *testing.T) {
+	legs := &fakeLegs{}
+	a := New(legs)
+	assert.NoError(t, a.Register(testAnimation))
+	assert.Equal(t, []string{"test"}, a.Names())
+
+	state := &hexapod.State{}
+	now := time.Now()
+
+	// Unknown animations are ignored.
+	state.Animation = "nope"
+	assert.NoError(t, a.Tick(now, state))
+	assert.Equal(t, "", state.Animation)
+	assert.Nil(t, state.Frame)
+
+	// Playing an animation asks the legs to hand over, and waits.
+	state.Animation = "test"
+	assert.NoError(t, a.Tick(now, state))
+	assert.Equal(t, "", state.Animation)
+	assert.Equal(t, "test", a.Playing())
+	assert.Equal(t, &hexapod.Frame{}, state.Frame)
+
+	now = now.Add(time.Second)
+	assert.NoError(t, a.Tick(now, state))
+	assert.Equal(t, &hexapod.Frame{}, state.Frame)
+
+	// Once they have, the timeline starts.
+	state.Animating = true
+	assert.NoError(t, a.Tick(now, state))
+	assert.NotNil(t, state.LookAt)
+
+	now = now.Add(time.Second)
+	assert.NoError(t, a.Tick(now, state))
+	assert.InDelta(t, 10, state.Frame.Body.Position.Y, 1e-9)
+
+	// Stopping returns to the start early.
+	a.Stop()
+	assert.NoError(t, a.Tick(now, state))
+	assert.InDelta(t, 10, state.Frame.Body.Position.Y, 1e-9)
+
+	now = now.Add(time.Second)
+	assert.NoError(t, a.Tick(now, state))
+	assert.InDelta(t, 5, state.Frame.Body.Position.Y, 1e-9)
+
+	now = now.Add(time.Second)
+	assert.NoError(t, a.Tick(now, state))
+	assert.Nil(t, state.Frame)
+	assert.Equal(t, "", a.Playing())
+
+	// The head wasn't aimed at anything before, so it's released.
+	assert.Nil(t, state.LookAt)
+
+	// If it was, it's aimed back at the same point.
+	target := math3d.Vector3{X: 100, Y: 50, Z: 300}
+	state.LookAt = &target
+	state.Animation = "test"
+	assert.NoError(t, a.Tick(now, state))
+	assert.NoError(t, a.Tick(now, state))
+	assert.Equal(t, "test", a.Playing())
+
+	now = now.Add(10 * time.Second)
+	assert.NoError(t, a.Tick(now, state))
+	assert.Nil(t, state.Frame)
+	assert.Equal(t, &target, state.LookAt)
+}
+
+func TestLoadDir(t *testing.T) {
+	a := New(&fakeLegs{})
+	assert.NoError(t, a.LoadDir("../../animations"))
+	assert.Contains(t, a.Names(), "wave")
+}
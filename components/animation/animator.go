@@ -0,0 +1,264 @@
+package animation
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/adammck/hexapod"
+	"github.com/adammck/hexapod/math3d"
+)
+
+var log = logrus.WithFields(logrus.Fields{
+	"pkg": "animation",
+})
+
+// The point (in the hexapod space) to aim the head at during animations, if it
+// wasn't aimed at anything before: straight ahead, level with the head.
+var defaultLookAt = math3d.Vector3{X: 0, Y: 43, Z: 500}
+
+// HasLegs is implemented by the legs, which the animations start from.
+type HasLegs interface {
+	FootPositions() [6]math3d.Vector3
+	LegMatrices() [6]math3d.Matrix44
+}
+
+// Animator plays animations, when they're requested (by name) via the state.
+// It must be added after the legs, so it can take over as soon as they hand
+// over, and after anything which aims the head, so it can override them.
+type Animator struct {
+	Legs HasLegs
+
+	animations map[string]Animation
+
+	// The animation which is playing (or waiting for the legs to hand over),
+	// or nil if none is.
+	playing *Animation
+
+	// The resolved keyframes of the playing animation, or nil if we're still
+	// waiting for the legs.
+	timeline timeline
+
+	// The time at which the timeline started.
+	start time.Time
+
+	// The pose at the start, to transform the head target into the world.
+	origin math3d.Pose
+
+	// The point which the head was aimed at before the animation took it over,
+	// to restore at the end. Nil if it wasn't aimed at anything.
+	lookAt *math3d.Vector3
+
+	// Set to true to return to the start early. This is cleared once we start
+	// returning.
+	stop      bool
+	returning bool
+}
+
+func New(l HasLegs) *Animator {
+	return &Animator{
+		Legs:       l,
+		animations: map[string]Animation{},
+	}
+}
+
+// Register adds an animation, replacing any existing animation with the same
+// name. Returns an error if the animation is invalid.
+func (a *Animator) Register(anim Animation) error {
+	err := anim.Validate()
+	if err != nil {
+		return err
+	}
+
+	a.animations[anim.Name] = anim
+	return nil
+}
+
+// LoadDir registers the animations defined in every JSON file in the given
+// directory.
+func (a *Animator) LoadDir(dir string) error {
+	aa, err := LoadDir(dir)
+	if err != nil {
+		return err
+	}
+
+	for _, anim := range aa {
+		err = a.Register(anim)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Names returns the name of every animation, sorted.
+func (a *Animator) Names() []string {
+	names := make([]string, 0, len(a.animations))
+
+	for n := range a.animations {
+		names = append(names, n)
+	}
+
+	sort.Strings(names)
+	return names
+}
+
+// Playing returns the name of the animation which is playing, or an empty
+// string if none is.
+func (a *Animator) Playing() string {
+	if a.playing == nil {
+		return ""
+	}
+
+	return a.playing.Name
+}
+
+// Stop returns to the start of the playing animation early, if one is playing.
+func (a *Animator) Stop() {
+	a.stop = true
+}
+
+func (a *Animator) Boot() error {
+	return nil
+}
+
+func (a *Animator) Tick(now time.Time, state *hexapod.State) error {
+	if state.Animation != "" {
+		err := a.play(state)
+		if err != nil {
+			log.Warn(err)
+		}
+	}
+
+	if a.playing == nil {
+		a.stop = false
+		return nil
+	}
+
+	// If we're still waiting for the legs, there's nothing to return from. Just
+	// withdraw the request.
+	if a.timeline == nil && (a.stop || state.Shutdown) {
+		log.Infof("cancelled animation: %s", a.playing.Name)
+		a.finish(state)
+		return nil
+	}
+
+	// Wait for the legs to finish their step cycle and hand over.
+	if a.timeline == nil {
+		if !state.Animating {
+			return nil
+		}
+
+		a.begin(now, state)
+	}
+
+	if (a.stop || state.Shutdown) && !a.returning {
+		a.cut(now)
+	}
+
+	p, done := a.timeline.at(now.Sub(a.start).Seconds())
+
+	// Hand back to the legs. They're already where they started, so can carry
+	// on walking from there.
+	if done {
+		log.Infof("finished animation: %s", a.playing.Name)
+		a.finish(state)
+		return nil
+	}
+
+	state.Frame = &hexapod.Frame{
+		Body: p.body,
+		Feet: p.feet,
+	}
+
+	v := p.lookAt.MultiplyByMatrix44(a.origin.ToWorld())
+	state.LookAt = &v
+
+	return nil
+}
+
+// play starts the animation requested via the state. The legs take over once
+// they've seen the frame, at the end of their step cycle.
+func (a *Animator) play(state *hexapod.State) error {
+	name := state.Animation
+	state.Animation = ""
+
+	anim, ok := a.animations[name]
+	if !ok {
+		return fmt.Errorf("unknown animation: %q", name)
+	}
+
+	if a.playing != nil {
+		return fmt.Errorf("can't play %q while %q is playing", name, a.playing.Name)
+	}
+
+	if state.Shutdown {
+		return fmt.Errorf("can't play %q while shutting down", name)
+	}
+
+	log.Infof("playing animation: %s", name)
+	a.playing = &anim
+	a.timeline = nil
+	a.stop = false
+	a.returning = false
+	state.Frame = &hexapod.Frame{}
+
+	return nil
+}
+
+// begin resolves the timeline of the playing animation, starting from wherever
+// the legs are now.
+func (a *Animator) begin(now time.Time, state *hexapod.State) {
+	start := pose{
+		feet:   a.Legs.FootPositions(),
+		lookAt: defaultLookAt,
+	}
+
+	a.lookAt = nil
+	if state.LookAt != nil {
+		start.lookAt = state.LookAt.MultiplyByMatrix44(state.Pose.ToLocal())
+		v := *state.LookAt
+		a.lookAt = &v
+	}
+
+	a.origin = state.Pose
+	a.timeline = makeTimeline(*a.playing, start, a.Legs.LegMatrices())
+	a.start = now
+}
+
+// cut replaces the rest of the timeline with a return to the start, from
+// wherever we are now.
+func (a *Animator) cut(now time.Time) {
+	p, _ := a.timeline.at(now.Sub(a.start).Seconds())
+
+	ret := a.playing.Return
+	if ret == 0 {
+		ret = defaultReturn
+	}
+
+	a.timeline = timeline{
+		{pose: p},
+		{pose: a.timeline[0].pose, at: ret, easing: EaseInOut},
+	}
+
+	a.start = now
+	a.stop = false
+	a.returning = true
+}
+
+// finish hands back to the legs, and the head back to whatever it was aimed at
+// before, if the animation had started.
+func (a *Animator) finish(state *hexapod.State) {
+	if a.timeline != nil {
+		state.LookAt = a.lookAt
+	}
+
+	a.lookAt = nil
+	a.playing = nil
+	a.timeline = nil
+	a.stop = false
+	a.returning = false
+	state.Frame = nil
+}
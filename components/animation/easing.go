@@ -0,0 +1,43 @@
+package animation
+
+// Easing is how to move from one keyframe to the next.
+type Easing string
+
+const (
+	Linear    Easing = "linear"
+	EaseIn    Easing = "in"
+	EaseOut   Easing = "out"
+	EaseInOut Easing = "in-out"
+)
+
+func (e Easing) valid() bool {
+	switch e {
+	case "", Linear, EaseIn, EaseOut, EaseInOut:
+		return true
+	}
+
+	return false
+}
+
+// apply returns the fraction of the way (from 0 to 1) between two keyframes
+// which we should be, at the given fraction of the time between them. The
+// default is linear.
+func (e Easing) apply(t float64) float64 {
+	switch e {
+	case EaseIn:
+		return t * t * t
+
+	case EaseOut:
+		u := 1 - t
+		return 1 - (u * u * u)
+
+	case EaseInOut:
+		if t < 0.5 {
+			return 4 * t * t * t
+		}
+		u := -2*t + 2
+		return 1 - (u * u * u / 2)
+	}
+
+	return t
+}
@@ -0,0 +1,46 @@
+package animation
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/adammck/hexapod"
+)
+
+// Handlers returns the HTTP handlers for the animator.
+func (a *Animator) Handlers() map[string]hexapod.HandlerFunc {
+	return map[string]hexapod.HandlerFunc{
+		"/animation": a.serveAnimation,
+	}
+}
+
+// serveAnimation returns the animation which is playing and the names of every
+// available animation as JSON. POSTing a name plays that animation, once the
+// legs have finished their step cycle, and DELETE returns from it early.
+func (a *Animator) serveAnimation(w http.ResponseWriter, r *http.Request, state *hexapod.State) {
+	switch r.Method {
+	case http.MethodPost:
+		name := r.FormValue("name")
+		if _, ok := a.animations[name]; !ok {
+			http.Error(w, fmt.Sprintf("unknown animation: %q", name), http.StatusBadRequest)
+			return
+		}
+
+		log.Infof("Animation=%s (via HTTP)", name)
+		state.Animation = name
+
+	case http.MethodDelete:
+		log.Info("animation stopped (via HTTP)")
+		a.Stop()
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(struct {
+		Playing    string   `json:"playing"`
+		Animations []string `json:"animations"`
+	}{
+		Playing:    a.Playing(),
+		Animations: a.Names(),
+	})
+}
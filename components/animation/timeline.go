@@ -0,0 +1,122 @@
+package animation
+
+import (
+	"github.com/adammck/hexapod/math3d"
+)
+
+const (
+
+	// The time (in seconds) to take to return to the start after the last
+	// keyframe, if the animation doesn't say.
+	defaultReturn = 1.0
+)
+
+// pose is everything which can be animated, at a single point in time.
+type pose struct {
+	body   math3d.Pose
+	feet   [6]math3d.Vector3
+	lookAt math3d.Vector3
+}
+
+// lerp returns the pose the given fraction of the way from p to pp.
+func (p pose) lerp(pp pose, f float64) pose {
+	var r pose
+
+	r.body = math3d.Pose{
+		Position: lerpVector(p.body.Position, pp.body.Position, f),
+		Heading:  lerpFloat(p.body.Heading, pp.body.Heading, f),
+		Pitch:    lerpFloat(p.body.Pitch, pp.body.Pitch, f),
+		Bank:     lerpFloat(p.body.Bank, pp.body.Bank, f),
+	}
+
+	for i := range p.feet {
+		r.feet[i] = lerpVector(p.feet[i], pp.feet[i], f)
+	}
+
+	r.lookAt = lerpVector(p.lookAt, pp.lookAt, f)
+	return r
+}
+
+func lerpFloat(a, b, f float64) float64 {
+	return a + ((b - a) * f)
+}
+
+func lerpVector(a, b math3d.Vector3, f float64) math3d.Vector3 {
+	return math3d.Vector3{
+		X: lerpFloat(a.X, b.X, f),
+		Y: lerpFloat(a.Y, b.Y, f),
+		Z: lerpFloat(a.Z, b.Z, f),
+	}
+}
+
+// key is a keyframe, with everything resolved into the hexapod space at the
+// start of the animation.
+type key struct {
+	pose
+
+	// The time (in seconds since the start) at which we should arrive here,
+	// and how to get here from the previous key.
+	at     float64
+	easing Easing
+}
+
+// timeline is a sequence of keys, starting at zero.
+type timeline []key
+
+// makeTimeline resolves the keyframes of the given animation, starting and
+// ending at the given pose. The matrices transform each leg space into the
+// hexapod space.
+func makeTimeline(a Animation, start pose, legs [6]math3d.Matrix44) timeline {
+	tl := make(timeline, 0, len(a.Keyframes)+2)
+	tl = append(tl, key{pose: start})
+
+	p := start
+	t := 0.0
+
+	for _, k := range a.Keyframes {
+		if k.Body != nil {
+			p.body = k.Body.pose()
+		}
+
+		for _, f := range k.Feet {
+			i := legIndex(f.Leg)
+			v := f.vector()
+
+			// Leg positions are relative to wherever the leg is in this
+			// keyframe, after the body has moved.
+			if f.Space == "leg" {
+				v = v.MultiplyByMatrix44(legs[i]).MultiplyByMatrix44(p.body.ToWorld())
+			}
+
+			p.feet[i] = v
+		}
+
+		if k.LookAt != nil {
+			p.lookAt = k.LookAt.vector()
+		}
+
+		t += k.Duration
+		tl = append(tl, key{pose: p, at: t, easing: k.Easing})
+	}
+
+	ret := a.Return
+	if ret == 0 {
+		ret = defaultReturn
+	}
+
+	return append(tl, key{pose: start, at: t + ret, easing: EaseInOut})
+}
+
+// at returns the pose at the given time (in seconds since the start), and
+// whether the timeline has finished.
+func (tl timeline) at(t float64) (pose, bool) {
+	for i := 1; i < len(tl); i++ {
+		if t < tl[i].at {
+			prev, next := tl[i-1], tl[i]
+			f := (t - prev.at) / (next.at - prev.at)
+			return prev.lerp(next.pose, next.easing.apply(f)), false
+		}
+	}
+
+	return tl[len(tl)-1].pose, true
+}
@@ -37,11 +37,31 @@ const (
 	// TODO: Document what 'offset' is, here and in the legs.
 	xOffsetScale = 40.0
 	zOffsetScale = 40.0
+
+	// Maximum distance (in mm) to move the foot of the manual leg from its
+	// lifted position using the right stick.
+	manualFootScale = 60.0
 )
 
+// The names of the legs which can be controlled directly, in the order which
+// they're selected by pressing left and right.
+var legNames = []string{"FL", "FR", "MR", "BR", "BL", "ML"}
+
 type Controller struct {
 	sa *sixaxis.SA
 
+	// The names of the gaits which can be cycled through by pressing select +
+	// triangle. If empty, the gait can't be changed via the controller.
+	Gaits []string
+
+	// The names of the compliance profiles which can be cycled through by
+	// pressing select + square.
+	Compliances []string
+
+	// The names of the animations which can be played by holding L1 and
+	// pressing triangle, circle, cross, or square (in that order).
+	Animations []string
+
 	clearance float64
 
 	// Keep track of whether various buttons were being pressed during the
@@ -54,6 +74,15 @@ type Controller struct {
 
 	// Track select + button options, which change states.
 	selectTriangle Latch
+	selectSquare   Latch
+
+	// Track L1 + button options, which play animations.
+	animLatches [4]Latch
+
+	// Toggle manual leg mode by pressing select + circle. The index (in
+	// legNames) of the leg to control is remembered between toggles.
+	selectCircle Latch
+	manualLeg    int
 
 	// Enable target orientation mode, where the target bank/pitch (x/y) are set
 	// using the controller orientation. Press the PS button to toggle. Defaults
@@ -115,8 +144,21 @@ func (c *Controller) Tick(now time.Time, state *hexapod.State) error {
 		state.Target.Bank = 0
 	}
 
-	// Set offset using the right stick while R1 is held down.
-	if c.sa.R1 > minButtonPressure {
+	// In manual leg mode, move the foot using the right stick, on the X/Z plane
+	// (in the leg space), or the X/Y plane while R1 is held down.
+	if state.ManualLeg != "" {
+		x := float64(c.sa.RightStick.X) / 127.0 * manualFootScale
+		y := float64(c.sa.RightStick.Y*-1) / 127.0 * manualFootScale
+
+		if c.sa.R1 > minButtonPressure {
+			state.ManualFoot = math3d.Vector3{X: x, Y: y}
+		} else {
+			state.ManualFoot = math3d.Vector3{X: x, Z: y}
+		}
+
+	} else if c.sa.R1 > minButtonPressure {
+
+		// Set offset using the right stick while R1 is held down.
 		state.Offset = math3d.Vector3{
 			X: (float64(c.sa.RightStick.X) / 127.0 * xOffsetScale),
 			Z: (float64(c.sa.RightStick.Y*-1) / 127.0 * zOffsetScale),
@@ -160,23 +202,74 @@ func (c *Controller) Tick(now time.Time, state *hexapod.State) error {
 		log.Infof("clearance=%v", c.clearance)
 	}
 
-	// Increase speed by pressing right
+	// Increase speed by pressing right, or select the next leg in manual leg
+	// mode.
 	if c.rightLatch.Run(c.sa.Right > minButtonPressure) {
-		state.Speed += 1
-		log.Infof("Speed=%v", state.Speed)
+		if state.ManualLeg != "" {
+			c.manualLeg = (c.manualLeg + 1) % len(legNames)
+			state.ManualLeg = legNames[c.manualLeg]
+			log.Infof("ManualLeg=%v", state.ManualLeg)
+		} else {
+			state.Speed += 1
+			log.Infof("Speed=%v", state.Speed)
+		}
 	}
 
-	// Decrease speed by pressing left
+	// Decrease speed by pressing left, or select the previous leg in manual leg
+	// mode.
 	if c.leftLatch.Run(c.sa.Left > minButtonPressure) {
-		state.Speed -= 1
-		log.Infof("Speed=%v", state.Speed)
+		if state.ManualLeg != "" {
+			c.manualLeg = (c.manualLeg + len(legNames) - 1) % len(legNames)
+			state.ManualLeg = legNames[c.manualLeg]
+			log.Infof("ManualLeg=%v", state.ManualLeg)
+		} else {
+			state.Speed -= 1
+			log.Infof("Speed=%v", state.Speed)
+		}
+	}
+
+	// Toggle manual leg mode by pressing select + circle.
+	if c.selectCircle.Run(c.sa.Select && c.sa.Circle > minButtonPressure) {
+		if state.ManualLeg == "" {
+			state.ManualLeg = legNames[c.manualLeg]
+		} else {
+			state.ManualLeg = ""
+		}
+		log.Infof("ManualLeg=%v", state.ManualLeg)
 	}
 
 	// Cycle through gaits by pressing select + triangle
-	if c.selectTriangle.Run(c.sa.Select && c.sa.Triangle > minButtonPressure) {
-		state.GaitIndex += 1
-		log.Infof("GaitIndex=%v", state.GaitIndex)
+	if c.selectTriangle.Run(c.sa.Select && c.sa.Triangle > minButtonPressure) && len(c.Gaits) > 0 {
+		state.Gait = next(c.Gaits, state.Gait)
+		log.Infof("Gait=%v", state.Gait)
+	}
+
+	// Cycle through compliance profiles by pressing select + square.
+	if c.selectSquare.Run(c.sa.Select && c.sa.Square > minButtonPressure) && len(c.Compliances) > 0 {
+		state.Compliance = next(c.Compliances, state.Compliance)
+		log.Infof("Compliance=%v", state.Compliance)
+	}
+
+	// Play animations by pressing L1 + triangle, circle, cross, or square.
+	l1 := c.sa.L1 > minButtonPressure
+	for i, b := range []int{c.sa.Triangle, c.sa.Circle, c.sa.Cross, c.sa.Square} {
+		if c.animLatches[i].Run(l1 && b > minButtonPressure) && i < len(c.Animations) {
+			state.Animation = c.Animations[i]
+			log.Infof("Animation=%v", state.Animation)
+		}
 	}
 
 	return nil
 }
+
+// next returns the name after the given one in the given list. If the given
+// name isn't in the list, returns the first one.
+func next(names []string, name string) string {
+	for i, n := range names {
+		if n == name {
+			return names[(i+1)%len(names)]
+		}
+	}
+
+	return names[0]
+}
@@ -6,10 +6,9 @@ import (
 	"time"
 
 	"github.com/Sirupsen/logrus"
-	"github.com/adammck/dynamixel/servo"
 	"github.com/adammck/hexapod"
+	"github.com/adammck/hexapod/actuator"
 	"github.com/adammck/hexapod/math3d"
-	"github.com/adammck/hexapod/servos"
 	"github.com/adammck/hexapod/utils"
 )
 
@@ -18,8 +17,13 @@ var log = logrus.WithFields(logrus.Fields{
 })
 
 const (
-	moveSpeed   = 1023
-	torqueLimit = 1023
+	// As fast and strong as possible.
+	moveSpeed = 0
+	torque    = 1.0
+
+	// The approximate mass (in grams) of the head assembly, including both
+	// servos and the camera.
+	Mass = 180.0
 )
 
 type Config struct {
@@ -38,31 +42,33 @@ var defaultConfig = &Config{
 
 type Head struct {
 	o math3d.Pose
-	h *servo.Servo
-	v *servo.Servo
+	h actuator.Actuator
+	v actuator.Actuator
 	c *Config
 }
 
-func New(o math3d.Pose, h, v *servo.Servo) *Head {
+// New creates a head at the given pose, driven by the given horizontal and
+// vertical actuators.
+func New(o math3d.Pose, h, v actuator.Actuator) *Head {
 	return &Head{o, h, v, defaultConfig}
 }
 
-func (h *Head) Servos() []*servo.Servo {
-	return []*servo.Servo{
+func (h *Head) Actuators() []actuator.Actuator {
+	return []actuator.Actuator{
 		h.h,
 		h.v,
 	}
 }
 
 func (h *Head) Boot() error {
-	for _, s := range h.Servos() {
+	for _, a := range h.Actuators() {
 
-		err := s.SetMovingSpeed(moveSpeed)
+		err := a.SetSpeed(moveSpeed)
 		if err != nil {
 			return fmt.Errorf("%s (while setting move speed)", err)
 		}
 
-		err = s.SetTorqueLimit(torqueLimit)
+		err = a.SetTorque(torque)
 		if err != nil {
 			return fmt.Errorf("%s (while setting torque limit)", err)
 		}
@@ -94,7 +100,7 @@ func (h *Head) Tick(now time.Time, state *hexapod.State) error {
 
 	// Update servos every tick.
 	// TODO: Maybe only update if the x/y has changed.
-	servos.RegMoveTo(h.h, x)
-	servos.RegMoveTo(h.v, y)
+	h.h.SetGoal(x)
+	h.v.SetGoal(y)
 	return nil
 }
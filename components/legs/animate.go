@@ -0,0 +1,46 @@
+package legs
+
+import (
+	"github.com/adammck/hexapod"
+	"github.com/adammck/hexapod/math3d"
+)
+
+// FootPositions returns the most recent goal of each foot, in the hexapod
+// space. This is where the animations start from.
+func (l *Legs) FootPositions() [6]math3d.Vector3 {
+	return l.goals
+}
+
+// LegMatrices returns a matrix for each leg, to transform a vector in its leg
+// space into the hexapod space.
+func (l *Legs) LegMatrices() [6]math3d.Matrix44 {
+	var m [6]math3d.Matrix44
+
+	for i, leg := range l.Legs {
+		m[i] = leg.Matrix()
+	}
+
+	return m
+}
+
+// animate sets the goal of each foot to its position in the given frame of an
+// animation. Feet which can't reach their position stay where they are, since
+// the animations aren't checked against the legs when they're loaded.
+func (l *Legs) animate(f *hexapod.Frame, state *hexapod.State) {
+	var goals [6]math3d.Vector3
+	local := f.Body.ToLocal()
+
+	for i, leg := range l.Legs {
+		goals[i] = f.Feet[i].MultiplyByMatrix44(local)
+
+		if leg.Disabled == "" {
+			_, err := leg.solve(goals[i])
+			if err != nil {
+				log.Warnf("%s (while animating %s leg)", err, leg.Name)
+				goals[i] = l.goals[i]
+			}
+		}
+	}
+
+	l.setGoals(goals, state)
+}
@@ -0,0 +1,48 @@
+package legs
+
+import (
+	"testing"
+
+	"github.com/adammck/hexapod"
+	"github.com/adammck/hexapod/math3d"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAnimate(t *testing.T) {
+	l, state, tick := steppingLegs(t, 60)
+
+	// Request an animation. The legs carry on until the end of the cycle.
+	tick()
+	state.Frame = &hexapod.Frame{}
+	for i := 0; i < 100 && !state.Animating; i++ {
+		tick()
+	}
+	assert.True(t, state.Animating)
+	assert.Equal(t, sAnimating, l.State)
+	assert.Equal(t, [6]bool{}, l.swinging)
+
+	// Moving the body in the frame moves the feet the opposite way.
+	start := l.FootPositions()
+	state.Frame = &hexapod.Frame{Body: math3d.Pose{Position: math3d.Vector3{X: 10}}, Feet: start}
+	tick()
+	for i := range l.Legs {
+		assert.InDelta(t, start[i].X-10, l.goals[i].X, 1e-6)
+		assert.InDelta(t, start[i].Z, l.goals[i].Z, 1e-6)
+	}
+
+	// Feet can be moved individually.
+	lifted := start
+	lifted[0].Y += 30
+	state.Frame = &hexapod.Frame{Feet: lifted}
+	tick()
+	assert.Equal(t, lifted, l.goals)
+
+	// Once the frames stop, the legs go back to stepping from where they were.
+	state.Frame = nil
+	tick()
+	assert.False(t, state.Animating)
+	assert.Equal(t, sStepping, l.State)
+	for i := range l.Legs {
+		assert.InDelta(t, 0, start[i].Distance(l.goals[i]), 1e-6)
+	}
+}
@@ -0,0 +1,184 @@
+package legs
+
+import (
+	"fmt"
+	"reflect"
+
+	"github.com/adammck/hexapod"
+	"github.com/adammck/hexapod/math3d"
+)
+
+const (
+
+	// The top and bottom (on the Y axis, in the hexapod space) of the body.
+	// From above, it's the convex hull of the leg origins.
+	bodyBottom = 10.0
+	bodyTop    = 60.0
+
+	// The number of points along each segment to check against the body.
+	bodySamples = 5
+)
+
+// The radius (in mm) of the capsule around each segment of a leg, from the
+// coxa to the tarsus. These are rough, and err on the fat side, since the
+// servos stick out from the brackets.
+var segmentRadii = [4]float64{20, 18, 15, 8}
+
+// capsules returns a capsule around each segment of the leg, in the hexapod
+// space, with the joints at the given angles.
+func (leg *Leg) capsules(a Angles) [4]math3d.Capsule {
+	var c [4]math3d.Capsule
+
+	for i, s := range leg.segments(a) {
+		c[i] = math3d.Capsule{A: s.Start(), B: s.End(), Radius: segmentRadii[i]}
+	}
+
+	return c
+}
+
+// legClearance returns the smallest distance (in mm) between the surfaces of
+// two legs, or the depth of the overlap (as a negative number) if they touch.
+func legClearance(a, b [4]math3d.Capsule) float64 {
+	min := a[0].Clearance(b[0])
+
+	for i := range a {
+		for j := range b {
+			if c := a[i].Clearance(b[j]); c < min {
+				min = c
+			}
+		}
+	}
+
+	return min
+}
+
+// bodyClearance returns the smallest distance (in mm) between the tibia and
+// tarsus of a leg and the given body, or the depth of the overlap (as a
+// negative number) if they touch. The coxa and femur are attached to the body,
+// so can't meaningfully touch it.
+func bodyClearance(c [4]math3d.Capsule, body math3d.Polygon) float64 {
+	min := 0.0
+	first := true
+
+	for _, s := range c[2:] {
+		for i := 0; i < bodySamples; i++ {
+			p := s.A.Add(s.B.Subtract(s.A).MultiplyByScalar(float64(i) / float64(bodySamples-1)))
+
+			// This is the distance to a box (rather than a capsule), which is
+			// close enough, since the body is flat.
+			flat := -body.Margin(*p)
+			vert := bodyBottom - p.Y
+			if p.Y-bodyTop > vert {
+				vert = p.Y - bodyTop
+			}
+
+			d := flat
+			if vert > d {
+				d = vert
+			}
+			d -= s.Radius
+
+			if first || d < min {
+				min = d
+				first = false
+			}
+		}
+	}
+
+	return min
+}
+
+// body returns the outline of the body, seen from above.
+func (l *Legs) body() math3d.Polygon {
+	pts := make([]math3d.Vector3, len(l.Legs))
+
+	for i, leg := range l.Legs {
+		pts[i] = *leg.Origin
+	}
+
+	return math3d.ConvexHull(pts)
+}
+
+// checkCollisions returns the contacts (between adjacent legs, and between
+// legs and the body) which would occur if the legs moved from the previous
+// angles to the next, and which legs must not move to avoid them. Legs which
+// are already touching something may still move away from it. Disabled legs
+// are ignored.
+func (l *Legs) checkCollisions(prev, next [6]Angles) ([]string, [6]bool) {
+	var contacts []string
+	var blocked [6]bool
+
+	var pc, nc [6][4]math3d.Capsule
+	for i, leg := range l.Legs {
+		if leg.Disabled == "" {
+			pc[i] = leg.capsules(prev[i])
+			nc[i] = leg.capsules(next[i])
+		}
+	}
+
+	for i, leg := range l.Legs {
+		if leg.Disabled != "" {
+			continue
+		}
+
+		j := (i + 1) % len(l.Legs)
+		if l.Legs[j].Disabled == "" {
+			c := legClearance(nc[i], nc[j])
+			if c < 0 {
+				contacts = append(contacts, fmt.Sprintf("%s/%s", leg.Name, l.Legs[j].Name))
+
+				if c < legClearance(pc[i], pc[j]) {
+					blocked[i] = true
+					blocked[j] = true
+				}
+			}
+		}
+
+		body := l.body()
+		c := bodyClearance(nc[i], body)
+		if c < 0 {
+			contacts = append(contacts, fmt.Sprintf("%s/body", leg.Name))
+
+			if c < bodyClearance(pc[i], body) {
+				blocked[i] = true
+			}
+		}
+	}
+
+	return contacts, blocked
+}
+
+// setGoals sets the goal of each leg to the given vector in the hexapod space,
+// like setGoal, except that legs which would move into each other (or the
+// body) stay where they are. The contacts are published to the state.
+func (l *Legs) setGoals(v [6]math3d.Vector3, state *hexapod.State) {
+	var prev, next [6]Angles
+
+	for i, leg := range l.Legs {
+		prev[i] = leg.Goal
+		next[i] = leg.Goal
+
+		// Unreachable goals are handled by setGoal.
+		a, err := leg.solve(v[i])
+		if err == nil {
+			next[i] = a
+		}
+	}
+
+	contacts, blocked := l.checkCollisions(prev, next)
+
+	if !reflect.DeepEqual(contacts, state.Collisions) && len(contacts) > 0 {
+		log.Warnf("prevented collisions: %v", contacts)
+	}
+	state.Collisions = contacts
+
+	for i, leg := range l.Legs {
+		if !blocked[i] {
+			l.setGoal(i, v[i])
+
+			if leg.Goal != prev[i] {
+				l.setSpeeds(leg, prev[i])
+			}
+		}
+	}
+}
@@ -0,0 +1,92 @@
+package legs
+
+import (
+	"testing"
+
+	"github.com/adammck/hexapod"
+	"github.com/adammck/hexapod/math3d"
+	"github.com/stretchr/testify/assert"
+)
+
+func standingAngles(l *Legs) [6]Angles {
+	var a [6]Angles
+	for i, leg := range l.Legs {
+		a[i] = leg.Goal
+	}
+	return a
+}
+
+func TestCheckCollisionsStanding(t *testing.T) {
+	l := standingLegs(t, 40)
+	a := standingAngles(l)
+
+	contacts, blocked := l.checkCollisions(a, a)
+	assert.Empty(t, contacts)
+	assert.Equal(t, [6]bool{}, blocked)
+}
+
+func TestCheckCollisionsLegs(t *testing.T) {
+	l := standingLegs(t, 40)
+	prev := standingAngles(l)
+
+	// Swing FR round into MR.
+	next := prev
+	next[1].Coxa += 70
+
+	contacts, blocked := l.checkCollisions(prev, next)
+	assert.Equal(t, []string{"FR/MR"}, contacts)
+	assert.Equal(t, [6]bool{false, true, true, false, false, false}, blocked)
+
+	// Moving apart again is allowed, even though they're still touching.
+	back := next
+	back[1].Coxa -= 2
+
+	contacts, blocked = l.checkCollisions(next, back)
+	assert.Equal(t, []string{"FR/MR"}, contacts)
+	assert.Equal(t, [6]bool{}, blocked)
+
+	// Disabled legs are ignored.
+	l.Legs[2].Disabled = "broken"
+	contacts, blocked = l.checkCollisions(prev, next)
+	assert.Empty(t, contacts)
+	assert.Equal(t, [6]bool{}, blocked)
+}
+
+func TestCheckCollisionsBody(t *testing.T) {
+	l := standingLegs(t, 40)
+	prev := standingAngles(l)
+
+	// Curl the foot of ML up and under the body.
+	next := prev
+	next[5], _ = l.Legs[5].solve(math3d.Vector3{X: -40, Y: 20, Z: 0})
+
+	contacts, blocked := l.checkCollisions(prev, next)
+	assert.Equal(t, []string{"ML/body"}, contacts)
+	assert.Equal(t, [6]bool{false, false, false, false, false, true}, blocked)
+}
+
+func TestSetGoals(t *testing.T) {
+	l := standingLegs(t, 40)
+	state := &hexapod.State{}
+
+	var goals [6]math3d.Vector3
+	for i, leg := range l.Legs {
+		goals[i] = leg.segments(leg.Goal)[3].End()
+	}
+	l.setGoals(goals, state)
+	assert.Empty(t, state.Collisions)
+
+	// Try to move FR's foot right next to MR's.
+	prev := l.Legs[1].Goal
+	next := goals
+	next[1] = *goals[2].Add(math3d.Vector3{Z: 20})
+	l.setGoals(next, state)
+	assert.Equal(t, []string{"FR/MR"}, state.Collisions)
+	assert.Equal(t, prev, l.Legs[1].Goal)
+
+	// Other legs still move.
+	next[0] = *goals[0].Add(math3d.Vector3{Y: 10})
+	l.setGoals(next, state)
+	assert.InDelta(t, 0, next[0].Distance(l.Legs[0].segments(l.Legs[0].Goal)[3].End()), 1e-6)
+	assert.Equal(t, prev, l.Legs[1].Goal)
+}
@@ -0,0 +1,66 @@
+package legs
+
+import (
+	"github.com/adammck/hexapod/math3d"
+)
+
+const (
+
+	// The approximate mass (in grams) of each segment of a leg, including the
+	// servo at the end of it and any brackets. The coxa servo is mounted on the
+	// chassis, so it's included there.
+	coxaMass   = 70.0
+	femurMass  = 70.0
+	tibiaMass  = 65.0
+	tarsusMass = 20.0
+
+	// The mass (in grams) of the chassis, including the coxa servos, the Pi,
+	// and the battery.
+	chassisMass = 600.0
+)
+
+var (
+
+	// The center of mass of the chassis, relative to the hexapod origin. The
+	// battery sits in the middle, so it's just above the origin.
+	chassisCenter = math3d.Vector3{X: 0, Y: 24, Z: 0}
+)
+
+// PointMass is a mass (in grams) at some position in the hexapod space. This
+// is used to describe things attached to the chassis, like the head.
+type PointMass struct {
+	Position math3d.Vector3
+	Mass     float64
+}
+
+// legMasses returns the mass of each segment of a leg, in the same order as
+// Leg.segments.
+func legMasses() [4]float64 {
+	return [4]float64{coxaMass, femurMass, tibiaMass, tarsusMass}
+}
+
+// CenterOfMass returns the center of mass of the whole hex in the world space,
+// given the pose of the chassis. The legs are assumed to be at the angles most
+// recently set by SetGoal, which is close enough if they're keeping up.
+func (l *Legs) CenterOfMass(pose math3d.Pose) math3d.Vector3 {
+	sum := chassisCenter.MultiplyByScalar(chassisMass)
+	total := chassisMass
+
+	for _, pm := range l.Payload {
+		sum = *sum.Add(pm.Position.MultiplyByScalar(pm.Mass))
+		total += pm.Mass
+	}
+
+	masses := legMasses()
+	for _, leg := range l.Legs {
+		for i, seg := range leg.segments(leg.Goal) {
+
+			// Treat each segment as a point mass at its midpoint.
+			mid := seg.Start().Add(seg.End()).MultiplyByScalar(0.5)
+			sum = *sum.Add(mid.MultiplyByScalar(masses[i]))
+			total += masses[i]
+		}
+	}
+
+	return sum.MultiplyByScalar(1 / total).MultiplyByMatrix44(pose.ToWorld())
+}
@@ -0,0 +1,124 @@
+package legs
+
+import (
+	"testing"
+	"time"
+
+	"github.com/adammck/hexapod"
+	"github.com/adammck/hexapod/components/legs/gait"
+	"github.com/adammck/hexapod/math3d"
+	"github.com/stretchr/testify/assert"
+)
+
+// standingLegs returns a Legs with no servos attached, and the built-in gaits,
+// with every leg at the angles needed to stand with the origin at the given
+// height. The feet are placed symmetrically around the origin, which the home
+// positions aren't quite; they're nudged forwards a little.
+func standingLegs(t *testing.T, height float64) *Legs {
+	l := &Legs{
+		Legs: [6]*Leg{
+			{Name: "FL", Origin: math3d.MakeVector3(-61.167, 24, 98), Angle: 300},
+			{Name: "FR", Origin: math3d.MakeVector3(61.167, 24, 98), Angle: 60},
+			{Name: "MR", Origin: math3d.MakeVector3(81, 24, 0), Angle: 90},
+			{Name: "BR", Origin: math3d.MakeVector3(61.167, 24, -98), Angle: 120},
+			{Name: "BL", Origin: math3d.MakeVector3(-61.167, 24, -98), Angle: 240},
+			{Name: "ML", Origin: math3d.MakeVector3(-81, 24, 0), Angle: 270},
+		},
+		Gaits: gait.NewRegistry(),
+	}
+
+	pose := math3d.Pose{Position: math3d.Vector3{Y: height}}
+	for _, leg := range l.Legs {
+		foot := l.homeFootPosition(&math3d.Vector3{Z: -10}, leg, math3d.Pose{})
+		a, err := leg.solve(foot.MultiplyByMatrix44(pose.ToLocal()))
+		if err != nil {
+			t.Fatalf("%s: %s", leg.Name, err)
+		}
+		leg.Goal = a
+	}
+
+	return l
+}
+
+// steppingLegs returns a Legs like standingLegs, but with the feet at their
+// home positions, ready to step in the tripod gait. Also returns the state,
+// with the hex standing still, and a function to tick at the given FPS.
+func steppingLegs(t *testing.T, fps int) (*Legs, *hexapod.State, func()) {
+	l := standingLegs(t, 40)
+	l.MinStabilityMargin = defaultMinStabilityMargin
+	for i, leg := range l.Legs {
+		l.feet[i] = l.homeFootPosition(&math3d.ZeroVector3, leg, math3d.Pose{})
+	}
+	l.ready = true
+	l.SetState(sStepping)
+
+	state := &hexapod.State{Gait: "tripod"}
+	state.Pose.Position.Y = 40
+	state.Target = state.Pose
+
+	now := time.Now()
+	tick := func() {
+		now = now.Add(time.Second / time.Duration(fps))
+		err := l.Tick(now, state)
+		assert.NoError(t, err)
+	}
+
+	return l, state, tick
+}
+
+func TestCenterOfMassStanding(t *testing.T) {
+	l := standingLegs(t, 40)
+
+	// With nothing attached, the hex is symmetrical on both the X and Z axes,
+	// so the center of mass must be directly above the origin. The knees stick
+	// up above the chassis, so it's a little higher than that.
+	com := l.CenterOfMass(math3d.Pose{Position: math3d.Vector3{Y: 40}})
+	assert.InDelta(t, 0, com.X, 0.01)
+	assert.InDelta(t, 0, com.Z, 0.01)
+	assert.True(t, com.Y > 40+chassisCenter.Y && com.Y < 40+chassisCenter.Y+femurLength, "expected Y above chassis, got %0.2f", com.Y)
+
+	// Adding the head (in front of the origin) shifts the center of mass
+	// forwards by its share of the total mass.
+	total := chassisMass + (6 * (coxaMass + femurMass + tibiaMass + tarsusMass))
+	l.Payload = []PointMass{{Position: math3d.Vector3{X: 0, Y: 43, Z: 70}, Mass: 180}}
+	com = l.CenterOfMass(math3d.Pose{Position: math3d.Vector3{Y: 40}})
+	assert.InDelta(t, 0, com.X, 0.01)
+	assert.InDelta(t, (180*70)/(total+180), com.Z, 0.01)
+
+	// Moving the chassis moves the center of mass with it.
+	moved := l.CenterOfMass(math3d.Pose{Position: math3d.Vector3{X: 100, Y: 40, Z: -50}})
+	assert.InDelta(t, com.X+100, moved.X, 0.01)
+	assert.InDelta(t, com.Y, moved.Y, 0.01)
+	assert.InDelta(t, com.Z-50, moved.Z, 0.01)
+}
+
+func TestCenterOfMassKnownAnswer(t *testing.T) {
+	l := &Legs{}
+
+	// Six identical legs, all sticking straight out along the Z axis from the
+	// same origin, so the midpoint of each segment is easy to work out by hand.
+	// Relative to the leg origin, they're at:
+	//
+	//   coxa   (0,  -6,  19.5)   70g
+	//   femur  (0, -12,  89)     70g
+	//   tibia  (0, -12, 181.5)   65g
+	//   tarsus (0, -12, 264.25)  20g
+	//
+	for i := range l.Legs {
+		l.Legs[i] = &Leg{Origin: math3d.MakeVector3(10, 24, 20), Angle: 0}
+	}
+
+	// Each leg is 225g, so the whole hex is 600 + (6 * 225) = 1950g. The moment
+	// of each leg (in gram-mm) about the hexapod origin is:
+	//
+	//   X: 225 * 10                                              =  2250
+	//   Y: 225 * 24 - (70 * 6) - (155 * 12)                      =  3120
+	//   Z: 225 * 20 + (70 * 19.5) + (70 * 89) + (65 * 181.5)
+	//        + (20 * 264.25)                                     = 29177.5
+	//
+	// Plus the chassis, at (0, 24, 0), which adds 600 * 24 = 14400 to Y.
+	com := l.CenterOfMass(math3d.Pose{})
+	assert.InDelta(t, (6*2250.0)/1950, com.X, 0.01)
+	assert.InDelta(t, (6*3120.0+14400)/1950, com.Y, 0.01)
+	assert.InDelta(t, (6*29177.5)/1950, com.Z, 0.01)
+}
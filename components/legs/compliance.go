@@ -0,0 +1,178 @@
+package legs
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/adammck/hexapod"
+	"github.com/adammck/hexapod/actuator"
+)
+
+const (
+
+	// The name of the compliance profile which picks soft or stiff, depending
+	// on whether we're walking.
+	autoCompliance = "auto"
+
+	// How long to stand still before going soft, when the compliance is auto.
+	softDelay = 1 * time.Second
+
+	// The slowest speed (in degrees per second) which a joint is set to move
+	// at, when it isn't moving at all.
+	minSpeed = 1.0
+)
+
+// Profile is the compliance of each joint of every leg: coxa, femur, tibia,
+// and tarsus.
+type Profile [4]actuator.Compliance
+
+// uniform returns a profile with the same compliance for every joint.
+func uniform(c actuator.Compliance) Profile {
+	return Profile{c, c, c, c}
+}
+
+var profiles = map[string]Profile{
+
+	// Slow and weak, while finding our feet at boot.
+	"boot": uniform(actuator.Compliance{Margin: 0.3, Slope: 9.4, Punch: 0.03, Torque: 0.25, Speed: 340}),
+
+	// The AX-12 defaults, at full strength. For walking.
+	"stiff": uniform(actuator.Compliance{Margin: 0.3, Slope: 9.4, Punch: 0.03, Torque: 1}),
+
+	// Gives way when pushed, so it's safer to poke at while standing still.
+	// The femurs and tibias hold the body up, so can't be too soft.
+	"soft": {
+		{Margin: 0.6, Slope: 37.5, Punch: 0.03, Torque: 0.375},
+		{Margin: 0.3, Slope: 37.5, Punch: 0.03, Torque: 0.75},
+		{Margin: 0.3, Slope: 37.5, Punch: 0.03, Torque: 0.75},
+		{Margin: 0.6, Slope: 37.5, Punch: 0.03, Torque: 0.375},
+	},
+}
+
+// ComplianceNames returns the names of the compliance profiles which can be
+// selected (via State.Compliance), with auto first.
+func ComplianceNames() []string {
+	names := []string{autoCompliance}
+	for name := range profiles {
+		if name != "boot" {
+			names = append(names, name)
+		}
+	}
+
+	sort.Strings(names[1:])
+	return names
+}
+
+// profileName returns the name of the compliance profile which should be used
+// right now. If auto (or nothing) is selected, that's stiff while moving (or
+// doing anything other than walking) and soft after standing still for a bit.
+func (l *Legs) profileName(now time.Time, state *hexapod.State) string {
+	if state.Compliance != "" && state.Compliance != autoCompliance {
+		if _, ok := profiles[state.Compliance]; ok {
+			return state.Compliance
+		}
+
+		log.Warnf("unknown compliance profile: %s", state.Compliance)
+		state.Compliance = autoCompliance
+	}
+
+	if l.State == sStepping && now.Sub(l.lastMoved) > softDelay {
+		return "soft"
+	}
+
+	return "stiff"
+}
+
+// compliance returns the compliance settings of every actuator for the given
+// profile, with the torque reduced by the given derating.
+func (l *Legs) compliance(p Profile, derating float64) map[int]actuator.Compliance {
+	cs := map[int]actuator.Compliance{}
+
+	for _, leg := range l.Legs {
+		for i, a := range leg.joints() {
+			if a == nil {
+				continue
+			}
+
+			c := p[i]
+			c.Torque *= 1 - derating
+			cs[a.ID()] = c
+		}
+	}
+
+	return cs
+}
+
+// setProfile applies the given compliance profile to every actuator.
+func (l *Legs) setProfile(name string, derating float64) error {
+	cs := l.compliance(profiles[name], derating)
+
+	for _, leg := range l.Legs {
+		for _, a := range leg.Actuators() {
+			err := actuator.SetCompliance(a, cs[a.ID()])
+			if err != nil {
+				return fmt.Errorf("%s (while setting %s compliance of servo #%d)", err, name, a.ID())
+			}
+		}
+	}
+
+	l.profile = name
+	l.derating = derating
+	l.settings = cs
+
+	return nil
+}
+
+// updateCompliance switches to a different compliance profile (or derating)
+// if it has changed since the previous tick. This does nothing until we've
+// started standing up.
+func (l *Legs) updateCompliance(now time.Time, state *hexapod.State) error {
+	if l.State == sDefault {
+		return nil
+	}
+
+	name := l.profileName(now, state)
+	if name == l.profile && state.Derating == l.derating {
+		return nil
+	}
+
+	log.Infof("compliance=%s, derating=%.2f", name, state.Derating)
+	return l.setProfile(name, state.Derating)
+}
+
+// setSpeeds sets the speed of each joint of the given leg, in proportion to
+// how far it has to move from the given angles to its goal, so they all get
+// there together at the end of the tick. The speeds are capped by the profile.
+// This only happens if JointSpeeds is enabled, since each speed might otherwise
+// need its own packet.
+func (l *Legs) setSpeeds(leg *Leg, prev Angles) {
+	if !l.JointSpeeds || l.dt == 0 {
+		return
+	}
+
+	deltas := [4]float64{
+		leg.Goal.Coxa - prev.Coxa,
+		leg.Goal.Femur - prev.Femur,
+		leg.Goal.Tibia - prev.Tibia,
+		leg.Goal.Tarsus - prev.Tarsus,
+	}
+
+	for i, a := range leg.joints() {
+		if a == nil {
+			continue
+		}
+
+		// Never zero, since that's as fast as possible.
+		speed := math.Max(math.Abs(deltas[i])/l.dt, minSpeed)
+		if max := l.settings[a.ID()].Speed; max > 0 && speed > max {
+			speed = max
+		}
+
+		err := a.SetSpeed(speed)
+		if err != nil {
+			log.Warnf("%s (while setting speed of servo #%d)", err, a.ID())
+		}
+	}
+}
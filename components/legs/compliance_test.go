@@ -0,0 +1,83 @@
+package legs
+
+import (
+	"testing"
+	"time"
+
+	"github.com/adammck/hexapod"
+	"github.com/adammck/hexapod/actuator"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestComplianceNames(t *testing.T) {
+	assert.Equal(t, []string{"auto", "soft", "stiff"}, ComplianceNames())
+}
+
+func TestProfileName(t *testing.T) {
+	l := standingLegs(t, 40)
+	state := &hexapod.State{}
+	now := time.Unix(100, 0)
+
+	// Stiff while standing up, or anything but stepping.
+	l.SetState(sStandUp)
+	assert.Equal(t, "stiff", l.profileName(now, state))
+
+	// Stiff while moving, soft after standing still for a bit.
+	l.SetState(sStepping)
+	l.lastMoved = now
+	assert.Equal(t, "stiff", l.profileName(now, state))
+	assert.Equal(t, "soft", l.profileName(now.Add(2*softDelay), state))
+
+	// Unless something else was selected.
+	state.Compliance = "stiff"
+	assert.Equal(t, "stiff", l.profileName(now.Add(2*softDelay), state))
+
+	// Unknown profiles are ignored.
+	state.Compliance = "squishy"
+	assert.Equal(t, "stiff", l.profileName(now, state))
+	assert.Equal(t, "auto", state.Compliance)
+}
+
+func TestCompliance(t *testing.T) {
+	l := standingLegs(t, 40)
+	coxa := actuator.NewSim(41)
+	l.Legs[0].Coxa = coxa
+	l.Legs[0].Femur = actuator.NewSim(42)
+	l.Legs[1].Tarsus = actuator.NewSim(54)
+
+	cs := l.compliance(profiles["soft"], 0.5)
+	assert.Len(t, cs, 3)
+	assert.Equal(t, 0.1875, cs[41].Torque)
+	assert.Equal(t, 0.375, cs[42].Torque)
+	assert.Equal(t, 0.1875, cs[54].Torque)
+	assert.Equal(t, 37.5, cs[54].Slope)
+
+	// Actuators which aren't compliant just get the torque and speed.
+	assert.NoError(t, l.setProfile("boot", 0.5))
+	assert.Equal(t, 0.125, coxa.Torque())
+	assert.Equal(t, 340.0, coxa.Speed())
+}
+
+func TestSetSpeeds(t *testing.T) {
+	l := standingLegs(t, 40)
+	l.JointSpeeds = true
+	l.dt = 0.1
+	l.settings = map[int]actuator.Compliance{2: {Speed: 150}}
+
+	sims := []*actuator.Sim{actuator.NewSim(1), actuator.NewSim(2), actuator.NewSim(3), actuator.NewSim(4)}
+	leg := l.Legs[0]
+	leg.Coxa, leg.Femur, leg.Tibia, leg.Tarsus = sims[0], sims[1], sims[2], sims[3]
+
+	prev := Angles{}
+	leg.SetAngles(Angles{Coxa: 10, Femur: 20, Tibia: 0, Tarsus: -5})
+	l.setSpeeds(leg, prev)
+
+	speeds := []float64{}
+	for _, s := range sims {
+		speeds = append(speeds, s.Speed())
+	}
+
+	// The femur would be 200, but is capped by the profile. The tibia isn't
+	// moving, but zero would be as fast as possible.
+	assert.Equal(t, []float64{100, 150, 1, 50}, speeds)
+}
@@ -0,0 +1,166 @@
+package legs
+
+import (
+	"github.com/adammck/hexapod"
+	"github.com/adammck/hexapod/math3d"
+)
+
+const (
+
+	// The number of consecutive ticks in which a leg can fail to set its goal
+	// before it's disabled.
+	maxLegFailures = 10
+
+	// The number of legs which can be disabled before we give up walking and
+	// sit down. Five legs can still walk (slowly) if the others spread out to
+	// cover the gap, but four can't.
+	maxDisabledLegs = 1
+
+	// The angle (in degrees) to rotate the home positions of the neighbours of
+	// a disabled leg towards it.
+	spreadAngle = 15.0
+
+	// The minimum time (in seconds) per step while a leg is disabled. With one
+	// fewer leg to hold the body up, there's less room for error.
+	degradedStepDuration = 0.5
+
+	// The distance (in mm) which the hex can move per step cycle while a leg is
+	// disabled. See maxStepDistance.
+	degradedStepDistance = 45.0
+
+	// The maximum distance (in mm) which the body may sway while a leg is
+	// disabled. See makeSway.
+	maxDegradedSway = 30.0
+)
+
+var (
+
+	// The position of a disabled foot, in the leg space. This is pulled in and
+	// up, clear of the ground and the neighbouring legs.
+	tuckPosition = math3d.Vector3{X: 0, Y: 30, Z: 110}
+)
+
+// disableLeg stops the given leg from walking, and adjusts the stance of the
+// other legs to make up for it. Any feet which are in the air are put down
+// where they are, and the step cycle is restarted, so the new gait starts
+// immediately. The hex stays put for the first cycle, while the other feet
+// step to their new home positions.
+func (l *Legs) disableLeg(n int, reason string) {
+	leg := l.Legs[n]
+	log.Errorf("disabling %s leg: %s", leg.Name, reason)
+	leg.Disabled = reason
+
+	l.Legs[(n+len(l.Legs)-1)%len(l.Legs)].spread += spreadAngle
+	l.Legs[(n+1)%len(l.Legs)].spread -= spreadAngle
+
+	for i := range l.Legs {
+		l.feet[i].Y = 0
+		l.swinging[i] = false
+	}
+
+	l.settling = true
+
+	if l.State == sStepping {
+		l.SetState(sStepping)
+	}
+}
+
+// disabled returns whether each leg is disabled.
+func (l *Legs) disabled() [6]bool {
+	var d [6]bool
+
+	for i, leg := range l.Legs {
+		d[i] = leg.Disabled != ""
+	}
+
+	return d
+}
+
+// degraded returns true if any leg is disabled.
+func (l *Legs) degraded() bool {
+	for _, d := range l.disabled() {
+		if d {
+			return true
+		}
+	}
+
+	return false
+}
+
+// disabledReasons returns the reason that each disabled leg was disabled, by
+// leg name, or nil if none are.
+func (l *Legs) disabledReasons() map[string]string {
+	var m map[string]string
+
+	for _, leg := range l.Legs {
+		if leg.Disabled != "" {
+			if m == nil {
+				m = map[string]string{}
+			}
+			m[leg.Name] = leg.Disabled
+		}
+	}
+
+	return m
+}
+
+// tuck moves the given (disabled) leg to its tucked position. Errors are
+// ignored, since some of the servos are probably broken anyway. This doesn't
+// use SetGoal, which panics if the position can't be reached.
+func (l *Legs) tuck(leg *Leg) {
+	a, err := leg.solve(tuckPosition.MultiplyByMatrix44(leg.Matrix()))
+	if err == nil {
+		err = leg.SetAngles(a)
+	}
+
+	if err != nil {
+		log.Debugf("%s (while tucking %s leg)", err, leg.Name)
+	}
+}
+
+// disableFailed disables any leg with a servo which the monitor couldn't bring
+// out of alarm.
+func (l *Legs) disableFailed(state *hexapod.State) {
+	if l.State == sDefault {
+		return
+	}
+
+	for i, leg := range l.Legs {
+		if leg.Disabled != "" {
+			continue
+		}
+
+		for _, a := range leg.Actuators() {
+			if reason, ok := state.FailedServos[a.ID()]; ok {
+				l.disableLeg(i, reason)
+				break
+			}
+		}
+	}
+}
+
+// setGoal sets the goal of the given leg to the given vector in the hexapod
+// space, or tucks it if it's disabled. If setting the goal keeps failing, the
+// leg is disabled.
+func (l *Legs) setGoal(n int, v math3d.Vector3) {
+	leg := l.Legs[n]
+
+	if leg.Disabled != "" {
+		l.tuck(leg)
+		return
+	}
+
+	err := leg.SetGoal(v)
+	if err == nil {
+		leg.failures = 0
+		l.goals[n] = v
+		return
+	}
+
+	log.Warnf("%s (while setting goal position)", err)
+
+	leg.failures += 1
+	if leg.failures >= maxLegFailures {
+		l.disableLeg(n, err.Error())
+	}
+}
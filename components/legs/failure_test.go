@@ -0,0 +1,111 @@
+package legs
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/adammck/dynamixel/network"
+	"github.com/adammck/hexapod/actuator"
+	"github.com/adammck/hexapod/fake/serial"
+	"github.com/adammck/hexapod/math3d"
+	"github.com/adammck/hexapod/servos"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDisableLeg(t *testing.T) {
+	l := standingLegs(t, 40)
+	l.disableLeg(2, "broken")
+
+	assert.Equal(t, map[string]string{"MR": "broken"}, l.disabledReasons())
+	assert.Equal(t, []int{0, 1, 3, 4, 5}, l.stance())
+
+	// The neighbours spread out towards the gap.
+	assert.Equal(t, spreadAngle, l.Legs[1].spread)
+	assert.Equal(t, -spreadAngle, l.Legs[3].spread)
+
+	// The tucked position must be reachable.
+	for _, leg := range l.Legs {
+		_, err := leg.solve(tuckPosition.MultiplyByMatrix44(leg.Matrix()))
+		assert.NoError(t, err, leg.Name)
+	}
+
+	// If it isn't, the leg is left where it is, rather than crashing.
+	defer func(v math3d.Vector3) { tuckPosition = v }(tuckPosition)
+	tuckPosition = math3d.Vector3{Z: 1000}
+	assert.NotPanics(t, func() { l.tuck(l.Legs[2]) })
+}
+
+func TestWalkDegraded(t *testing.T) {
+	for n := 0; n < 6; n++ {
+		l, state, tick := steppingLegs(t, 60)
+		state.Target = state.Pose.Add(math3d.Pose{Position: math3d.Vector3{Z: 1000}})
+
+		// Fail a leg mid-stride, and keep walking.
+		for i := 0; i < 1000; i++ {
+			if i == 50 {
+				l.disableLeg(n, "broken")
+			}
+
+			tick()
+
+			if i > 50 {
+				assert.True(t, state.Stability > 0, "leg=%d, tick=%d, stability=%0.2f", n, i, state.Stability)
+			}
+		}
+
+		assert.Equal(t, map[string]string{l.Legs[n].Name: "broken"}, state.DisabledLegs)
+		assert.True(t, state.Pose.Position.Z > 200, "leg=%d, z=%0.2f", n, state.Pose.Position.Z)
+		assert.Equal(t, 150, l.Gait.Length())
+	}
+}
+
+// brokenSerial is a fake serial port which fails every write once broken.
+type brokenSerial struct {
+	serial.FakeSerial
+	broken bool
+}
+
+func (s *brokenSerial) Write(p []byte) (int, error) {
+	if s.broken {
+		return 0, errors.New("broken")
+	}
+
+	return s.FakeSerial.Write(p)
+}
+
+func TestWalkSyncWriteFailure(t *testing.T) {
+	s := &brokenSerial{}
+	p := servos.NewPool(servos.NewBus("main", network.New(s)))
+	p.SyncWrite = true
+
+	l, state, tick := steppingLegs(t, 60)
+	state.Target = state.Pose.Add(math3d.Pose{Position: math3d.Vector3{Z: 1000}})
+
+	// Drive one leg with real servos, which only get their goals when the pool
+	// is flushed at the end of each tick, like the Hexapod does.
+	leg := l.Legs[2]
+	for i, a := range []*actuator.Actuator{&leg.Coxa, &leg.Femur, &leg.Tibia, &leg.Tarsus} {
+		j, err := p.Actuator(30 + i)
+		assert.NoError(t, err)
+		*a = j
+	}
+
+	step := func() {
+		tick()
+		p.Action()
+	}
+
+	for i := 0; i < 60; i++ {
+		step()
+	}
+	assert.Nil(t, state.DisabledLegs)
+
+	// Once the bus stops taking writes, the leg is disabled, and the others
+	// walk on without it.
+	s.broken = true
+	for i := 0; i < maxLegFailures+1; i++ {
+		step()
+	}
+	assert.Contains(t, state.DisabledLegs, "MR")
+	assert.Len(t, state.DisabledLegs, 1)
+}
@@ -0,0 +1,58 @@
+package legs
+
+import (
+	"github.com/adammck/hexapod"
+	"github.com/adammck/hexapod/math3d"
+)
+
+// Foot is the measured position of a foot which is on the ground.
+type Foot struct {
+	Leg string
+
+	// The position of the end of the leg in the hexapod space, calculated from
+	// the present angles of the servos.
+	Position math3d.Vector3
+
+	// The number of steps which this foot has taken since boot. If this hasn't
+	// changed between two measurements, the foot hasn't moved (in the world
+	// space) in between.
+	Steps int
+}
+
+// StanceFeet returns the measured position of each foot which is currently on
+// the ground. This involves reading the position of every servo in those legs,
+// so it isn't quick. Nothing is returned until the legs are ready.
+func (l *Legs) StanceFeet() ([]Foot, error) {
+	if !l.ready {
+		return nil, nil
+	}
+
+	feet := make([]Foot, 0, len(l.Legs))
+
+	for _, i := range l.stance() {
+		if l.swinging[i] {
+			continue
+		}
+
+		leg := l.Legs[i]
+		v, err := leg.PresentPosition()
+		if err != nil {
+			return nil, err
+		}
+
+		feet = append(feet, Foot{
+			Leg:      leg.Name,
+			Position: v,
+			Steps:    l.steps[i],
+		})
+	}
+
+	return feet, nil
+}
+
+// BodyPose returns the commanded pose of the chassis in the world space, i.e.
+// the pose which the feet are positioned relative to. This differs from the
+// pose in the state by the offset and any sway.
+func (l *Legs) BodyPose(state *hexapod.State) math3d.Pose {
+	return l.bodyPose(state)
+}
@@ -1,7 +1,15 @@
 package gait
 
 const (
+
+	// Legs are numbered clockwise around the body (as viewed from above),
+	// starting at the front left, so leg n is adjacent to legs n-1 and n+1.
 	numLegs = 6
+
+	// The step height ratio (see Frame.Y) above which a foot is considered to
+	// have left the ground. The bell curve never quite reaches zero, so we can't
+	// just check for that.
+	LiftThreshold = 0.05
 )
 
 type Frame struct {
@@ -9,11 +17,26 @@ type Frame struct {
 	Y  float64
 }
 
+// Lifted returns true if the foot is off the ground during this frame.
+func (f Frame) Lifted() bool {
+	return f.Y >= LiftThreshold
+}
+
 type Frames []Frame
 
 type Gait struct {
 	legs   [numLegs]Frames
 	length int
+
+	// The center of the step of each leg, as a fraction of the cycle, and the
+	// number of ticks which each step takes. The frames are generated from
+	// these, and they're kept to blend between gaits.
+	centers      [numLegs]float64
+	ticksPerStep int
+
+	// Legs which never step, because they've been disabled. Their frames are
+	// all zero, and they're ignored by Valid.
+	disabled [numLegs]bool
 }
 
 // Length returns the number of ticks necessary to complete a full cycle of the
@@ -29,3 +52,88 @@ func (g *Gait) Length() int {
 func (g *Gait) Frame(leg int, n int) Frame {
 	return g.legs[leg][n]
 }
+
+// Disabled returns true if the given leg never steps in this gait.
+func (g *Gait) Disabled(leg int) bool {
+	return g.disabled[leg]
+}
+
+// Without returns a copy of the gait in which the given legs never step. The
+// remaining legs are unchanged, so this is only useful if the gait is still
+// stable without the disabled legs. See Degraded.
+func (g Gait) Without(disabled [numLegs]bool) Gait {
+	var legs [numLegs]Frames
+
+	for i := 0; i < numLegs; i += 1 {
+		if disabled[i] || g.disabled[i] {
+			legs[i] = make(Frames, g.length)
+			g.disabled[i] = true
+		} else {
+			legs[i] = g.legs[i]
+		}
+	}
+
+	g.legs = legs
+	return g
+}
+
+// SwingLength returns the number of frames during each cycle in which the given
+// leg is moving towards its next foothold.
+func (g *Gait) SwingLength(leg int) int {
+	n := 0
+
+	for _, f := range g.legs[leg] {
+		if f.XZ > 0 && f.XZ < 1 {
+			n += 1
+		}
+	}
+
+	return n
+}
+
+// SwingRemaining returns the number of frames, starting at frame n, until the
+// given leg reaches its next foothold. This is zero if the leg isn't moving.
+func (g *Gait) SwingRemaining(leg int, n int) int {
+	r := 0
+
+	for _, f := range g.legs[leg][n:] {
+		if f.XZ >= 1 || (r == 0 && f.XZ <= 0) {
+			break
+		}
+		r += 1
+	}
+
+	return r
+}
+
+// Valid returns true if every leg (except those which are disabled) takes
+// exactly one step per cycle, and no two adjacent legs are ever off the ground
+// at the same time.
+func (g *Gait) Valid() bool {
+	for i := 0; i < numLegs; i += 1 {
+		if g.disabled[i] {
+			continue
+		}
+
+		steps := 0
+		lifted := false
+
+		for n := 0; n < g.length; n += 1 {
+			if g.legs[i][n].Lifted() && !lifted {
+				steps += 1
+			}
+			lifted = g.legs[i][n].Lifted()
+
+			j := (i + 1) % numLegs
+			if lifted && g.legs[j][n].Lifted() {
+				return false
+			}
+		}
+
+		if steps != 1 {
+			return false
+		}
+	}
+
+	return true
+}
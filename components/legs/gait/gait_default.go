@@ -4,81 +4,81 @@ import (
 	"math"
 )
 
-func TheGait(groupSize int, ticksPerStep int) Gait {
-	ticksPerStepCycle := ticksPerStep * (6 / groupSize)
-	cc := curveCenters(groupSize, ticksPerStepCycle)
+// Transition returns a gait to run for a single cycle while switching between
+// two gaits (or two speeds of the same gait), to avoid abruptly jumping from
+// one to the other. The center of each leg's step, the step duration, and the
+// cycle duration are all halfway between the two.
+//
+// Legs which are disabled in either gait are disabled in the transition. If
+// that would have adjacent legs lifted at the same time, a wave gait is used
+// instead, since it's always valid, if a little slow.
+func Transition(from, to Gait) Gait {
+	var disabled [numLegs]bool
+	for i := 0; i < numLegs; i += 1 {
+		disabled[i] = from.disabled[i] || to.disabled[i]
+	}
 
-	var legs [numLegs]Frames
+	tps := (from.ticksPerStep + to.ticksPerStep) / 2
+	length := (from.length + to.length) / 2
+
+	// Keep each step entirely within the cycle, so it's only taken once.
+	w := (float64(tps) / float64(length)) / 2
+
+	var centers [numLegs]float64
 	for i := 0; i < numLegs; i += 1 {
-		legs[i] = singleLegGait(ticksPerStepCycle, ticksPerStep, cc[i])
+		c := (from.centers[i] + to.centers[i]) / 2
+		centers[i] = math.Max(w, math.Min(1-w, c))
 	}
 
-	return Gait{
-		legs:   legs,
-		length: ticksPerStepCycle,
+	g := makeGait(centers, tps, length).Without(disabled)
+	if g.Valid() {
+		return g
 	}
+
+	return Degraded(disabled, tps)
 }
 
-// curveCenters
-func curveCenters(groupSize int, ticksPerStepCycle int) [numLegs]float64 {
-	p := float64(ticksPerStepCycle) / 12
-	switch groupSize {
-
-	// Move one leg at a time (six groups):
-	//
-	// |1|2|3|4|5|6|7|8|9|0|1|2|
-	// |1|1|1|1|1|1|1|1|1|1|1|1|
-	//   ^   ^   ^   ^   ^   ^
-	//   1   3   5   7   9  11
-	case 1:
-		return [numLegs]float64{
-			0: p * 1,
-			1: p * 3,
-			2: p * 5,
-			3: p * 7,
-			4: p * 9,
-			5: p * 11,
+// Degraded returns a wave gait for a hex with some legs disabled. The others
+// each step in turn around the body, as in the wave gait, starting after the
+// first disabled leg. The cycle is a step shorter for each disabled leg. With
+// no legs disabled, it's just the wave gait.
+func Degraded(disabled [numLegs]bool, ticksPerStep int) Gait {
+	first := 0
+	for i := numLegs - 1; i >= 0; i -= 1 {
+		if disabled[i] {
+			first = i + 1
 		}
+	}
 
-	// Two at a time (three groups):
-	//
-	// |1|2|3|4|5|6|7|8|9|0|1|2|
-	// |-2-|---4---|---4---|-2-|
-	//     ^       ^       ^
-	//     2       6      10
-	case 2:
-		a := p * 2
-		b := p * 6
-		c := p * 10
-		return [numLegs]float64{
-			0: a, // FL
-			1: b, // FR
-			2: a, // MR
-			3: c, // BR
-			4: b, // BL
-			5: c, // ML
+	enabled := make([]int, 0, numLegs)
+	for j := 0; j < numLegs; j += 1 {
+		i := (first + j) % numLegs
+		if !disabled[i] {
+			enabled = append(enabled, i)
 		}
+	}
 
-	// Three (two groups):
-	//
-	// |1|2|3|4|5|6|7|8|9|0|1|2|
-	// |--3--|-----6-----|--3--|
-	//       ^           ^
-	//       3           9
-	case 3:
-		a := p * 3
-		b := p * 9
-		return [numLegs]float64{
-			0: a,
-			1: b,
-			2: a,
-			3: b,
-			4: a,
-			5: b,
-		}
+	var centers [numLegs]float64
+	for j, i := range enabled {
+		centers[i] = (float64(j) + 0.5) / float64(len(enabled))
+	}
 
-	default:
-		panic("invalid groupSize")
+	return makeGait(centers, ticksPerStep, ticksPerStep*len(enabled)).Without(disabled)
+}
+
+// makeGait returns a gait with the given step centers (as a fraction of the
+// cycle), step duration, and cycle duration (both in ticks).
+func makeGait(centers [numLegs]float64, ticksPerStep, ticksPerStepCycle int) Gait {
+	var legs [numLegs]Frames
+	for i := 0; i < numLegs; i += 1 {
+		legs[i] = singleLegGait(ticksPerStepCycle, ticksPerStep, centers[i]*float64(ticksPerStepCycle))
+	}
+
+	return Gait{
+		legs:         legs,
+		length:       ticksPerStepCycle,
+		centers:      centers,
+		ticksPerStep: ticksPerStep,
 	}
 }
 
@@ -0,0 +1,146 @@
+package gait
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// builtins returns the definition of every built-in gait.
+func builtins(t *testing.T) []Definition {
+	r := NewRegistry()
+
+	var ds []Definition
+	for _, n := range r.Names() {
+		d, _ := r.Get(n)
+		ds = append(ds, d)
+	}
+
+	return ds
+}
+
+// get returns the definition of the given built-in gait.
+func get(t *testing.T, name string) Definition {
+	d, ok := NewRegistry().Get(name)
+	if !ok {
+		t.Fatalf("no such gait: %s", name)
+	}
+
+	return d
+}
+
+func TestBuiltinsValid(t *testing.T) {
+	for _, d := range builtins(t) {
+		assert.NoError(t, d.Validate())
+
+		for _, tps := range []int{4, 20, 80} {
+			g := d.Gait(tps)
+			assert.True(t, g.Valid(), "gait=%s, tps=%d", d.Name, tps)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	d := get(t, "tripod")
+	d.Duty = 1
+	assert.Error(t, d.Validate())
+
+	// The step of the second leg would wrap around the end of the cycle.
+	d = get(t, "wave")
+	d.Offsets[1] = 0.9
+	assert.Error(t, d.Validate())
+
+	// The front legs would be lifted together.
+	d = get(t, "wave")
+	d.Offsets[1] = 0
+	assert.Error(t, d.Validate())
+}
+
+func TestRegistry(t *testing.T) {
+	r := NewRegistry()
+	assert.Equal(t, []string{"ripple", "tetrapod", "tripod", "wave"}, r.Names())
+
+	// Custom gaits are added alongside the built-in ones.
+	assert.NoError(t, r.LoadDir("testdata"))
+	assert.Equal(t, []string{"custom", "ripple", "tetrapod", "tripod", "wave"}, r.Names())
+
+	d, ok := r.Get("tripod")
+	assert.True(t, ok)
+	g := d.Gait(20)
+	assert.Equal(t, 40, g.Length())
+
+	assert.Error(t, r.Register(Definition{Name: "bad", Duty: 0.5}))
+	_, ok = r.Get("bad")
+	assert.False(t, ok)
+
+	// Built-in gaits can be replaced.
+	w := get(t, "wave")
+	w.Name = "tripod"
+	assert.NoError(t, r.Register(w))
+	d, _ = r.Get("tripod")
+	assert.Equal(t, w, d)
+}
+
+func TestTransitionValid(t *testing.T) {
+	ds := builtins(t)
+	for _, a := range ds {
+		for _, b := range ds {
+			for _, tps := range [][2]int{{20, 20}, {20, 4}, {4, 80}} {
+				from := a.Gait(tps[0])
+				to := b.Gait(tps[1])
+				g := Transition(from, to)
+				assert.True(t, g.Valid(), "from=%s/%d, to=%s/%d", a.Name, tps[0], b.Name, tps[1])
+
+				// Every step finishes within the cycle, so the feet are all on
+				// the ground when switching to the next gait.
+				for i := 0; i < numLegs; i += 1 {
+					assert.False(t, g.Frame(i, g.Length()-1).Lifted())
+					assert.False(t, g.Frame(i, 0).Lifted())
+				}
+			}
+		}
+	}
+}
+
+func TestTransitionSpeed(t *testing.T) {
+	tripod := get(t, "tripod")
+	from := tripod.Gait(20)
+	to := tripod.Gait(10)
+	g := Transition(from, to)
+
+	// Changing speed only changes the durations, so the steps stay in phase.
+	assert.Equal(t, 30, g.Length())
+	assert.Equal(t, 15, g.ticksPerStep)
+	assert.Equal(t, from.centers, g.centers)
+}
+
+func TestDegraded(t *testing.T) {
+
+	// With no legs disabled, it's the wave gait.
+	w := get(t, "wave").Gait(20)
+	g := Degraded([numLegs]bool{}, 20)
+	assert.Equal(t, w.Length(), g.Length())
+	assert.InDeltaSlice(t, w.centers[:], g.centers[:], 1e-3)
+
+	ds := builtins(t)
+	for n := 0; n < numLegs; n += 1 {
+		var disabled [numLegs]bool
+		disabled[n] = true
+
+		g := Degraded(disabled, 20)
+		assert.True(t, g.Valid(), "disabled=%d", n)
+		assert.Equal(t, 100, g.Length())
+		assert.Equal(t, 0, g.SwingLength(n))
+
+		// Switching to (and from) the degraded gait keeps the leg disabled.
+		for _, d := range ds {
+			g1 := Transition(d.Gait(20), g)
+			assert.True(t, g1.Valid(), "from=%s, disabled=%d", d.Name, n)
+			assert.True(t, g1.Disabled(n))
+
+			g2 := Transition(g, d.Gait(20))
+			assert.True(t, g2.Valid(), "to=%s, disabled=%d", d.Name, n)
+			assert.True(t, g2.Disabled(n))
+		}
+	}
+}
@@ -0,0 +1,175 @@
+package gait
+
+import (
+	"embed"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"path"
+	"path/filepath"
+	"sort"
+)
+
+// The built-in gaits are defined by JSON files, just like custom ones, but
+// embedded in the program so they're always available.
+//
+//go:embed builtin/*.json
+var builtin embed.FS
+
+// Definition describes a gait as data, independent of speed. Each leg takes a
+// single step per cycle; Offsets is the point in the cycle (from 0 to 1) at
+// which each leg lifts off, and Duty is the fraction of the cycle for which
+// each foot is on the ground.
+type Definition struct {
+	Name    string           `json:"name"`
+	Offsets [numLegs]float64 `json:"offsets"`
+	Duty    float64          `json:"duty"`
+}
+
+// Gait returns the frames of the gait, with each step taking the given number
+// of ticks.
+func (d Definition) Gait(ticksPerStep int) Gait {
+	swing := 1 - d.Duty
+	length := int(math.Floor((float64(ticksPerStep) / swing) + 0.5))
+
+	var centers [numLegs]float64
+	for i := 0; i < numLegs; i += 1 {
+		centers[i] = d.Offsets[i] + (swing / 2)
+	}
+
+	return makeGait(centers, ticksPerStep, length)
+}
+
+// Validate returns an error if the gait is unusable: if any leg would take
+// more (or less) than one step per cycle, or two adjacent legs would ever be
+// lifted at the same time.
+func (d Definition) Validate() error {
+	if d.Name == "" {
+		return fmt.Errorf("gait has no name")
+	}
+
+	if d.Duty <= 0 || d.Duty >= 1 {
+		return fmt.Errorf("gait %q: duty must be between 0 and 1, was %0.2f", d.Name, d.Duty)
+	}
+
+	// Each step must finish within the cycle, or it would be split into two,
+	// one at each end.
+	for i, o := range d.Offsets {
+		if o < 0 || o > d.Duty+1e-9 {
+			return fmt.Errorf("gait %q: leg %d offset must be between 0 and %0.2f, was %0.2f", d.Name, i, d.Duty, o)
+		}
+	}
+
+	g := d.Gait(20)
+	if !g.Valid() {
+		return fmt.Errorf("gait %q: adjacent legs are lifted at the same time", d.Name)
+	}
+
+	return nil
+}
+
+// Registry is a set of named gaits.
+type Registry struct {
+	gaits map[string]Definition
+}
+
+// NewRegistry returns a registry of the built-in gaits. Custom gaits can be
+// added to them, or replace them, with LoadDir.
+func NewRegistry() *Registry {
+	r := &Registry{
+		gaits: map[string]Definition{},
+	}
+
+	entries, err := builtin.ReadDir("builtin")
+	if err != nil {
+		panic(err)
+	}
+
+	for _, e := range entries {
+		p := path.Join("builtin", e.Name())
+
+		b, err := builtin.ReadFile(p)
+		if err != nil {
+			panic(err)
+		}
+
+		// The built-in gaits are checked by the tests, so this never happens.
+		err = r.load(b, p)
+		if err != nil {
+			panic(err)
+		}
+	}
+
+	return r
+}
+
+// Register adds a gait to the registry, replacing any existing gait with the
+// same name. Returns an error if the gait is invalid.
+func (r *Registry) Register(d Definition) error {
+	err := d.Validate()
+	if err != nil {
+		return err
+	}
+
+	r.gaits[d.Name] = d
+	return nil
+}
+
+// Get returns the gait with the given name.
+func (r *Registry) Get(name string) (Definition, bool) {
+	d, ok := r.gaits[name]
+	return d, ok
+}
+
+// Names returns the name of every gait in the registry, sorted.
+func (r *Registry) Names() []string {
+	names := make([]string, 0, len(r.gaits))
+
+	for n := range r.gaits {
+		names = append(names, n)
+	}
+
+	sort.Strings(names)
+	return names
+}
+
+// LoadFile registers the gait defined in the given JSON file.
+func (r *Registry) LoadFile(path string) error {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	return r.load(b, path)
+}
+
+// load registers the gait defined by the given JSON, which was read from the
+// given file.
+func (r *Registry) load(b []byte, name string) error {
+	var d Definition
+	err := json.Unmarshal(b, &d)
+	if err != nil {
+		return fmt.Errorf("%s (while parsing %s)", err, name)
+	}
+
+	return r.Register(d)
+}
+
+// LoadDir registers the gaits defined in every JSON file in the given
+// directory, replacing any (including the built-in ones) with the same name.
+func (r *Registry) LoadDir(dir string) error {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return err
+	}
+
+	for _, p := range paths {
+		err = r.LoadFile(p)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
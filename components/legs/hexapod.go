@@ -6,74 +6,113 @@ import (
 	"time"
 
 	"github.com/Sirupsen/logrus"
-	"github.com/adammck/dynamixel/network"
-	"github.com/adammck/dynamixel/servo"
 	"github.com/adammck/hexapod"
+	"github.com/adammck/hexapod/actuator"
 	"github.com/adammck/hexapod/components/legs/gait"
 	"github.com/adammck/hexapod/math3d"
+	"github.com/adammck/hexapod/trajectory"
 )
 
 type State string
 
 const (
-	sDefault  State = ""
-	sStandUp  State = "sStandUp"
-	sSitDown  State = "sSitDown"
-	sStepping State = "sStepping"
-
-	moveSpeedSlow   = 512
-	torqueLimitSlow = 256
-
-	moveSpeedFast   = 1023
-	torqueLimitFast = 1023
-
-	// The distance (in mm) to adjust the Y position to meet the Y target each
-	// tick. This mostly controls the time it takes to stand up and sit down.
-	yMoveSpeed = 1
-
-	bankMoveSpeed  = 0.5
-	pitchMoveSpeed = 0.5
+	sDefault   State = ""
+	sStandUp   State = "sStandUp"
+	sSitDown   State = "sSitDown"
+	sStepping  State = "sStepping"
+	sAnimating State = "sAnimating"
+	sManual    State = "sManual"
 
 	// Distance (on the X/Z axis) from the origin to the point at which the feet
 	// should be positioned. This isn't adjustable at runtime, because there are
 	// very few valid settings.
 	stepRadius = 240.0
 
-	// The number of ticks per step, i.e. a single foot is lifted, moved to its
-	// new position, and put down.
-	baseTicksPerStep = 20
+	// The time (in seconds) per step, i.e. a single foot is lifted, moved to
+	// its new position, and put down. Each increase in the speed in the state
+	// takes a little off.
+	baseStepDuration  = 1.0 / 3
+	speedStepDuration = 1.0 / 30
+
+	// The gait to use if none is selected.
+	defaultGait = "wave"
 
-	// The minimum number of ticks allowed per step.
+	// The shortest and longest time (in seconds) allowed per step.
+	minStepDuration = 1.0 / 15
+	maxStepDuration = 4.0 / 3
+
+	// The minimum number of ticks per step, however fast the main loop is
+	// running. Any fewer, and the step curve falls apart.
 	minTicksPerStep = 4
 
-	// The maximum number of ticks allowed per step.
-	maxTicksPerStep = 80
+	// The tick duration (in seconds) to assume until the first few ticks have
+	// been timed.
+	defaultTickDuration = 1.0 / 60
 
 	// The offset (on the Y axis) which feet should be moved to on the up step,
 	// relative to the origin.
 	stepHeight = 40.0
 
-	// Minimum distance which the desired foot position should be from its
-	// actual position before a step should be taken to correct it.
+	// Minimum distance which the target position should be from the actual
+	// position before we start walking towards it.
 	minStepDistance = 20.0
 
 	// Minimum distance to turn (heading), before making a step.
 	minTurnDistance = 5.0
 
+	// Minimum distance which a foot should be from its next foothold before a
+	// step should be taken to correct it.
+	minFootDistance = 10.0
+
 	// The distance (in mm) which the hex can move per step cycle. This should
 	// be determined experimentally; too high and the legs get tangled up.
 	maxStepDistance = 90.0
+
+	// The angle (in degrees) which the hex can turn per step cycle.
+	maxTurnDistance = 20.0
+
+	// The longest time (in seconds) to move along the trajectories in a single
+	// tick. If the main loop stalls, we'd rather slow down than lurch.
+	maxTickDuration = 0.1
+
+	// The longest time to hold the step cycle while waiting for it to be safe
+	// to lift the next foot. If it's still not safe by then, nothing is going
+	// to change, so sit down rather than stand there forever.
+	maxHoldDuration = 5 * time.Second
+)
+
+var (
+
+	// Limits on how quickly the height (in mm) of the body can change. This
+	// mostly controls the time it takes to stand up and sit down.
+	heightLimits = trajectory.Limits{Velocity: 60, Acceleration: 120, Jerk: 600}
+
+	// Limits on how quickly the body can bank and pitch (in degrees).
+	tiltLimits = trajectory.Limits{Velocity: 30, Acceleration: 90, Jerk: 450}
+
+	// Limits on how quickly the target which we're walking towards can move
+	// (in mm) and turn (in degrees). The legs can't walk any faster than that,
+	// and don't lurch when the target jumps.
+	walkLimits = trajectory.Limits{Velocity: 200, Acceleration: 400, Jerk: 2000}
+	turnLimits = trajectory.Limits{Velocity: 30, Acceleration: 90, Jerk: 450}
 )
 
 type Legs struct {
-	Network *network.Network
+
+	// Whether to set the speed of each joint every tick, so they all arrive
+	// together. This is only worth it if the speeds can be sent along with the
+	// goals, in one packet.
+	JointSpeeds bool
 
 	// The state that the legs are currently in.
 	State        State
 	stateCounter int
 	stateTime    time.Time
 
-	Gait gait.Gait
+	// The gaits which can be selected (by name) via the state, and the frames
+	// of the one which is currently running.
+	Gaits *gait.Registry
+	Gait  gait.Gait
 
 	// ???
 	Legs [6]*Leg
@@ -83,36 +122,120 @@ type Legs struct {
 	// tick loop.
 	ready bool
 
-	// The pose (copied from the state) at the start of the current step cycle.
-	// We use this to calculate the pose for each intra-cycle frame.
-	lastPose math3d.Pose
+	// The gait name and ticks per step which the current (or next) gait was
+	// generated with. The gait is only regenerated when these change.
+	gaitName string
+	gaitTPS  int
 
-	// Target pose at the end of the next step cycle. This is calculated (from
-	// the state.Target) at the start of each cycle, to avoid moving it around
-	// mid-cycle. This is encapsulated here (rather than in the state) because
-	// it's an implementation detail of the legs.
-	target math3d.Pose
+	// The gait to switch to at the start of the next cycle, while the current
+	// gait is a transition. See gait.Transition.
+	nextGait *gait.Gait
 
 	// Last known foot positions in the WORLD coordinate space. We must store
 	// them in this space rather than the hexapod space, so they stay put when
 	// we move the origin around.
 	feet [6]math3d.Vector3
 
-	// Foot positions at the start of current step cycle.
+	// Foot positions at the start of the current (or most recent) step of each
+	// foot.
 	lastFeet [6]math3d.Vector3
 
-	// World positions of the NEXT foot position. These are nil if we're okay
-	// with where the foot is now, but are set if the foot should be relocated.
+	// World positions of the foothold which each foot is stepping towards, or
+	// most recently stepped to.
 	nextFeet [6]math3d.Vector3
+
+	// Whether each foot is currently in the air, moving towards nextFeet.
+	swinging [6]bool
+
+	// The most recent goal of each foot, in the hexapod space.
+	goals [6]math3d.Vector3
+
+	// The index of the leg being controlled directly in the sManual state, and
+	// how far (from 0 to 1) it has been lifted.
+	manual     int
+	manualLift float64
+
+	// The number of steps which each foot has taken. See Foot.Steps.
+	steps [6]int
+
+	// The minimum stability margin (in mm) which must remain when a foot is
+	// lifted. See stabilityMargin.
+	MinStabilityMargin float64
+
+	// Things attached to the chassis (like the head) which affect the center of
+	// mass. The chassis and legs themselves are already accounted for.
+	Payload []PointMass
+
+	// Whether to sway the body towards the center of the support polygon while
+	// stepping. This is mostly useful in gaits which lift one leg at a time,
+	// when the center of mass is otherwise left close to the edge.
+	Sway bool
+
+	// The sway for each frame of the current gait (see makeSway), or nil if
+	// swaying is disabled.
+	swayTable []math3d.Vector3
+
+	// The current sway of the body, in the hexapod space. This is added to the
+	// offset, but doesn't affect the home positions of the feet.
+	sway math3d.Vector3
+
+	// Set to true while lifting the manual leg is being delayed, because it
+	// would leave the hex unstable. This is only to avoid flooding the log
+	// with warnings.
+	unstable bool
+
+	// The time at which the step cycle started being held, because lifting the
+	// next foot would leave the hex unstable, or zero if it isn't.
+	heldSince time.Time
+
+	// Set to true when a leg is disabled, to stay put until the end of the
+	// next step cycle, while the other feet move to their new positions.
+	settling bool
+
+	// The name of the compliance profile, and the derating, which the servos
+	// were last set to. And the resulting settings of each servo, by ID.
+	profile  string
+	derating float64
+	settings map[int]actuator.Compliance
+
+	// The duration of the current tick, in seconds. Zero on the first one.
+	dt float64
+
+	// The (moving average) duration of a tick, in seconds, to convert the step
+	// duration into ticks. Zero until the second tick.
+	tickDuration float64
+
+	// The last time that the body or any foot moved while stepping.
+	lastMoved time.Time
+
+	// Smooth trajectories for the height, bank, and pitch of the body, and for
+	// the position and heading of the origin while walking. The target in the
+	// state can jump around, but these can't.
+	height trajectory.Trajectory
+	bank   trajectory.Trajectory
+	pitch  trajectory.Trajectory
+	walk   trajectory.Trajectory
+	turn   trajectory.Trajectory
+
+	// The time of the previous tick, to find how far to move along the
+	// trajectories each tick.
+	lastTick time.Time
 }
 
 var log = logrus.WithFields(logrus.Fields{
 	"pkg": "legs",
 })
 
-func New(n *network.Network) *Legs {
+// New creates the legs, with their actuators created by the given factory.
+func New(f actuator.Factory) *Legs {
 	l := &Legs{
-		Network: n,
+		Gaits:              gait.NewRegistry(),
+		MinStabilityMargin: defaultMinStabilityMargin,
+		height:             trajectory.New(heightLimits),
+		bank:               trajectory.New(tiltLimits),
+		pitch:              trajectory.New(tiltLimits),
+		walk:               trajectory.New(walkLimits),
+		turn:               trajectory.New(turnLimits),
 		Legs: [6]*Leg{
 
 			// Leg origins are relative to the hexapod origin, which is the X/Z
@@ -122,25 +245,26 @@ func New(n *network.Network) *Legs {
 			// Note that the angles are the direction in which the leg is
 			// pointing, NOT the angle between the hex and leg origins.
 			//
-			NewLeg(n, 40, "FL", math3d.MakeVector3(-61.167, 24, 98), 300),  // Front Left  - 0
-			NewLeg(n, 50, "FR", math3d.MakeVector3(61.167, 24, 98), 60),    // Front Right - 1
-			NewLeg(n, 60, "MR", math3d.MakeVector3(81, 24, 0), 90),         // Mid Right   - 2
-			NewLeg(n, 10, "BR", math3d.MakeVector3(61.167, 24, -98), 120),  // Back Right  - 3
-			NewLeg(n, 20, "BL", math3d.MakeVector3(-61.167, 24, -98), 240), // Back Left   - 4
-			NewLeg(n, 30, "ML", math3d.MakeVector3(-81, 24, 0), 270),       // Mid Left    - 5
+			NewLeg(f, 40, "FL", math3d.MakeVector3(-61.167, 24, 98), 300),  // Front Left  - 0
+			NewLeg(f, 50, "FR", math3d.MakeVector3(61.167, 24, 98), 60),    // Front Right - 1
+			NewLeg(f, 60, "MR", math3d.MakeVector3(81, 24, 0), 90),         // Mid Right   - 2
+			NewLeg(f, 10, "BR", math3d.MakeVector3(61.167, 24, -98), 120),  // Back Right  - 3
+			NewLeg(f, 20, "BL", math3d.MakeVector3(-61.167, 24, -98), 240), // Back Left   - 4
+			NewLeg(f, 30, "ML", math3d.MakeVector3(-81, 24, 0), 270),       // Mid Left    - 5
 		},
 	}
 
+	// Walk without any legs which couldn't be initialized. This must happen
+	// before the feet are homed, since it moves the neighbouring feet.
+	for i, leg := range l.Legs {
+		if leg.Disabled != "" {
+			l.disableLeg(i, leg.Disabled)
+		}
+	}
+
 	// Initialize each foot to its home position. This will be written to the
 	// servos during boot.
-	l.feet = [6]math3d.Vector3{
-		l.homeFootPosition(&math3d.ZeroVector3, l.Legs[0], math3d.Pose{}),
-		l.homeFootPosition(&math3d.ZeroVector3, l.Legs[1], math3d.Pose{}),
-		l.homeFootPosition(&math3d.ZeroVector3, l.Legs[2], math3d.Pose{}),
-		l.homeFootPosition(&math3d.ZeroVector3, l.Legs[3], math3d.Pose{}),
-		l.homeFootPosition(&math3d.ZeroVector3, l.Legs[4], math3d.Pose{}),
-		l.homeFootPosition(&math3d.ZeroVector3, l.Legs[5], math3d.Pose{}),
-	}
+	l.feet = l.HomeFootPositions()
 
 	// Reset the state, to set the timer.
 	l.SetState(sDefault)
@@ -148,25 +272,96 @@ func New(n *network.Network) *Legs {
 	return l
 }
 
-func (l *Legs) makeGait(index, speed int) error {
-	idx := (index % 3) + 1
-	tps := clamp(minTicksPerStep, maxTicksPerStep, baseTicksPerStep-(speed*2))
-	log.Infof("Gait: index=%d, tps=%d", idx, tps)
-	l.Gait = gait.TheGait(idx, tps)
+// makeGait updates the gait at the start of a step cycle, if the gait name or
+// speed has changed. Rather than switching straight to the new gait, a single
+// cycle of a transitional gait is run first, to blend between the two.
+//
+// If any legs are disabled, the selected gait is ignored, and the others walk
+// slowly in a degraded wave gait instead.
+func (l *Legs) makeGait(name string, speed int) error {
+	if name == "" {
+		name = defaultGait
+	}
+
+	def, ok := l.Gaits.Get(name)
+	if !ok {
+		return fmt.Errorf("unknown gait: %s", name)
+	}
+
+	d := math.Max(minStepDuration, math.Min(maxStepDuration, baseStepDuration-(float64(speed)*speedStepDuration)))
+
+	disabled := l.disabled()
+	build := def.Gait
+	if l.degraded() {
+		d = math.Max(degradedStepDuration, d)
+		build = func(tps int) gait.Gait {
+			return gait.Degraded(disabled, tps)
+		}
+	}
+
+	// The gait is in ticks, so the steps take the same time whatever the frame
+	// rate.
+	tps := l.ticks(d)
+
+	// Whether a leg has been disabled since the current gait was generated.
+	// There's no point transitioning from a gait which includes it.
+	changed := false
+	for i := range disabled {
+		changed = changed || (disabled[i] != l.Gait.Disabled(i))
+	}
+
+	switch {
+
+	// Finish the transition started at the start of the previous cycle. If the
+	// gait has changed again since then, it'll be picked up next time.
+	case l.nextGait != nil && !changed:
+		l.Gait = *l.nextGait
+		l.nextGait = nil
+
+	// Nothing to do if the gait hasn't changed.
+	case l.Gait.Length() > 0 && name == l.gaitName && tps == l.gaitTPS && !changed:
+		return nil
+
+	// This is the first cycle since boot, so there's nothing to transition
+	// from. All of the feet are at their home positions anyway. Or a leg has
+	// just been disabled, and the feet have all been put down.
+	case l.Gait.Length() == 0 || changed:
+		log.Infof("Gait: name=%s, tps=%d, degraded=%v", name, tps, l.degraded())
+		l.Gait = build(tps)
+		l.nextGait = nil
+		l.gaitName = name
+		l.gaitTPS = tps
+
+	default:
+		log.Infof("Gait: name=%s, tps=%d (transitioning)", name, tps)
+		next := build(tps)
+		l.Gait = gait.Transition(l.Gait, next)
+		l.nextGait = &next
+		l.gaitName = name
+		l.gaitTPS = tps
+	}
+
+	// Always sway while degraded, since the gap leaves the center of mass
+	// close to the edge of the support polygon.
+	if l.Sway || l.degraded() {
+		l.swayTable = l.makeSway()
+	} else {
+		l.swayTable = nil
+	}
+
 	return nil
 }
 
 func (l *Legs) distanceFromHome() (float64, error) {
 	var td float64
 
-	// This isn't usually necessary, but since we're (probably) running outside
-	// of the main loop, we need to lock the network to avoid crosstalk.
-	l.Network.Lock()
-	defer l.Network.Unlock()
-
 	// Sum the total distance between the actual foot positions and the target
 	// positions. We use this to wait until each foot has reached its target.
 	for i, leg := range l.Legs {
+		if leg.Disabled != "" {
+			continue
+		}
+
 		pv, err := leg.PresentPosition()
 		if err != nil {
 			return 0, err
@@ -211,39 +406,31 @@ func (l *Legs) waitForReady() {
 func (l *Legs) Boot() error {
 
 	// Set all servos slow.
-	for _, s := range l.Servos() {
-
-		err := s.SetMovingSpeed(moveSpeedSlow)
-		if err != nil {
-			return fmt.Errorf("%s (while setting move speed)", err)
-		}
-
-		err = s.SetTorqueLimit(torqueLimitSlow)
-		if err != nil {
-			return fmt.Errorf("%s (while setting torque limit)", err)
-		}
+	err := l.setProfile("boot", 0)
+	if err != nil {
+		return err
 	}
 
 	// Set the target for each foot to its home position. This is buffered, and
 	// will be executed once all Boot methods have been called.
-	for i, leg := range l.Legs {
-		leg.SetGoal(l.feet[i])
+	for i := range l.Legs {
+		l.setGoal(i, l.feet[i])
 	}
 
 	go l.waitForReady()
 	return nil
 }
 
-func (l *Legs) Servos() []*servo.Servo {
-	s := make([]*servo.Servo, 0, 4*6)
+// Actuators returns all of the actuators of every leg, excluding any which
+// couldn't be initialized.
+func (l *Legs) Actuators() []actuator.Actuator {
+	a := make([]actuator.Actuator, 0, 4*6)
 
 	for _, leg := range l.Legs {
-		for _, servo := range leg.Servos() {
-			s = append(s, servo)
-		}
+		a = append(a, leg.Actuators()...)
 	}
 
-	return s
+	return a
 }
 
 func (l *Legs) SetState(s State) {
@@ -253,11 +440,23 @@ func (l *Legs) SetState(s State) {
 	l.State = s
 }
 
+// HomeFootPositions returns the home position of each foot, in the hexapod
+// space, while the body is at the origin.
+func (l *Legs) HomeFootPositions() [6]math3d.Vector3 {
+	var v [6]math3d.Vector3
+
+	for i, leg := range l.Legs {
+		v[i] = l.homeFootPosition(&math3d.ZeroVector3, leg, math3d.Pose{})
+	}
+
+	return v
+}
+
 // homeFootPosition returns a vector in the WORLD coordinate space for the home
 // position of the given leg.
 func (l *Legs) homeFootPosition(offset *math3d.Vector3, leg *Leg, pose math3d.Pose) math3d.Vector3 {
 	hyp := math.Sqrt((leg.Origin.X * leg.Origin.X) + (leg.Origin.Z * leg.Origin.Z))
-	v := pose.Add(math3d.Pose{*offset, 0, 0, 0}).Add(math3d.Pose{math3d.Vector3{0, 0, 10}, 0, 0, 0}).Add(math3d.Pose{*leg.Origin, leg.Angle, 0, 0}).Add(math3d.Pose{math3d.Vector3{0, 0, stepRadius - hyp}, 0, 0, 0}).Position
+	v := pose.Add(math3d.Pose{*offset, 0, 0, 0}).Add(math3d.Pose{math3d.Vector3{0, 0, 10}, 0, 0, 0}).Add(math3d.Pose{*leg.Origin, leg.Angle + leg.spread, 0, 0}).Add(math3d.Pose{math3d.Vector3{0, 0, stepRadius - hyp}, 0, 0, 0}).Position
 	v.Y = 0.0
 	return v
 }
@@ -269,22 +468,31 @@ func (l *Legs) Tick(now time.Time, state *hexapod.State) error {
 		return nil
 	}
 
+	// The body doesn't sway unless we're stepping.
+	swayTarget := math3d.ZeroVector3
+
+	// The time since the previous tick, in seconds. This is zero on the first
+	// tick, so nothing moves until the second.
+	dt := 0.0
+	if !l.lastTick.IsZero() {
+		dt = math.Min(maxTickDuration, now.Sub(l.lastTick).Seconds())
+	}
+	l.lastTick = now
+	l.dt = dt
+
+	if dt > 0 {
+		if l.tickDuration == 0 {
+			l.tickDuration = dt
+		} else {
+			l.tickDuration = ((l.tickDuration * 7) + dt) / 8
+		}
+	}
+
 	// TODO: Remove the state machine altogether? The first two are just waiting
 	//       for the pose to converge with target, which the third also does.
 	switch l.State {
+	// The servos are switched out of the boot profile after this.
 	case sDefault:
-		for _, s := range l.Servos() {
-			err := s.SetMovingSpeed(moveSpeedFast)
-			if err != nil {
-				return fmt.Errorf("%s (while setting move speed)", err)
-			}
-
-			err = s.SetTorqueLimit(torqueLimitFast)
-			if err != nil {
-				return fmt.Errorf("%s (while setting torque limit)", err)
-			}
-		}
-
 		l.SetState(sStandUp)
 
 	// After init, wait until the Y position has met the target Y position
@@ -315,133 +523,325 @@ func (l *Legs) Tick(now time.Time, state *hexapod.State) error {
 
 	case sStepping:
 
-		// If this is the first tick in a step cycle, calculate the next target
-		// position, which is simply the move distance in the direction of the
-		// actual target position (which may be further away).
+		// At the start of each cycle, regenerate the gait, in case this is the
+		// first cycle since boot, or the gait or speed has changed. If an
+		// unknown gait was selected, keep going with the current one.
 		if l.stateCounter == 1 {
+			err := l.makeGait(state.Gait, state.Speed)
+			if err != nil {
+				log.Warn(err)
+				if l.Gait.Length() == 0 {
+					return err
+				}
+				state.Gait = l.gaitName
+			}
+		}
 
-			// Record current state
-			l.lastPose = state.Pose
-			for i, _ := range l.Legs {
-				l.lastFeet[i] = l.feet[i]
+		if l.swayTable != nil {
+			swayTarget = l.swayTable[l.stateCounter-1]
+		}
+
+		// Before lifting any feet, check that the remaining feet will still
+		// support the hex. If not, hold the cycle at this frame until it's safe.
+		// This stops the machine rather than tip it over, if the offset (or the
+		// target) is pushing the body too far over the edge.
+		if !l.safeToLift(l.stateCounter-1, state) {
+			if l.heldSince.IsZero() {
+				log.Warnf("delaying step: stability margin would drop below %0.2fmm", l.MinStabilityMargin)
+				l.heldSince = now
 			}
 
-			// Ignore Y axis for target and pose; we take care of that below.
-			// TODO: Fix this ugly mess.
-			xzPosePos := state.Pose.Position
-			xzTargetPos := state.Target.Position
-			xzPosePos.Y = 0
-			xzTargetPos.Y = 0
-
-			vecToGoal := xzTargetPos.Subtract(xzPosePos)
-			distToGoal := vecToGoal.Magnitude()
-
-			// Cap the distance we wil (attempt to) step at the max.
-			distToStep := math.Min(distToGoal, maxStepDistance)
-
-			// If the target position is closer than the minimum, or the heading
-			// is close enough, we're finished. This is the end of the idle loop
-			// when the machine is standing still.
-			if distToStep < minStepDistance && math.Abs(state.Target.Heading-state.Pose.Heading) < minTurnDistance {
-				l.target = l.lastPose
-				//log.Infof("not stepping")
-				if state.Shutdown {
-					l.SetState(sSitDown)
-				} else {
-					l.SetState(sStepping)
-				}
+			// The end of the cycle won't be reached while held, so check for
+			// shutdown here. Give up if we've been stuck for too long.
+			if !state.Shutdown && now.Sub(l.heldSince) > maxHoldDuration {
+				log.Errorf("step held for %s; shutting down", now.Sub(l.heldSince))
+				state.Shutdown = true
+			}
+
+			if state.Shutdown {
+				l.putDown()
+				l.heldSince = time.Time{}
+				l.SetState(sSitDown)
 				break
 			}
 
-			// Generate the gait for this step cycle, in case this is the first
-			// step since boot, or the gait index has changed since last time.
-			l.makeGait(state.GaitIndex, state.Speed)
+			// The sway table assumes that the feet are at their home positions,
+			// which they aren't just after a leg has been disabled. Sway towards
+			// the feet which will be left on the ground instead, or we'd be stuck.
+			if l.degraded() {
+				swayTarget = l.swayTowards(l.stanceAt(l.stateCounter-1), state)
+			}
 
-			// Calculate the target position for the origin.
-			vecToStep := vecToGoal.Unit().MultiplyByScalar(distToStep)
-			l.target.Position = *l.lastPose.Position.Add(vecToStep)
-			l.target.Heading = state.Target.Heading
-			log.Infof("stepping from %v to %v", l.lastPose, l.target)
+			// Start walking again from rest, once it's safe.
+			l.stop(state)
 
-			// Calculate the target position for each foot. Might be where they
-			// already are, if we're not stepping.
-			for i, leg := range l.Legs {
-				l.nextFeet[i] = l.homeFootPosition(&state.Offset, leg, l.target)
-			}
+			l.stateCounter -= 1
+			break
 		}
+		l.heldSince = time.Time{}
+
+		// Move the origin continuously at the commanded velocity. Note that we
+		// don't bother with the rotation (for now), so the hex will walk
+		// sideways or backwards if the target happens to be in that direction.
+		v, turn := l.velocity(dt, state)
+		state.Pose.Position = *state.Pose.Position.Add(v)
+		state.Pose.Heading += turn
+
+		// Update the position of each foot according to the precomputed map.
+		// Each foot picks its next foothold as it's lifted, so changes to the
+		// velocity take effect within a single step.
+		for i, leg := range l.Legs {
+			if leg.Disabled != "" {
+				continue
+			}
 
-		// Move continuously towards target. Note that we don't bother with the
-		// rotation (for now), so the hex will walk sideways or backwards if the
-		// target happens to be in that direction.
-		r := float64(l.stateCounter) / float64(l.Gait.Length())
-		v := l.target.Position.Subtract(l.lastPose.Position)
-		rr := l.target.Heading - l.lastPose.Heading
+			f := l.Gait.Frame(i, l.stateCounter-1)
 
-		// Ignore Y axis; we set that below, without tweening.
-		y := state.Pose.Position.Y
-		state.Pose.Position = *l.lastPose.Position.Add(v.MultiplyByScalar(r))
-		state.Pose.Position.Y = y
+			// Lift-off. The foothold is the home position at the pose that the
+			// origin will be at halfway through the foot's next stance, if the
+			// velocity doesn't change. That way the foot is under its home
+			// position in the middle of the stance, whichever way we're going.
+			if !l.swinging[i] && f.XZ > 0 && f.XZ < 1 {
+				swing := l.Gait.SwingRemaining(i, l.stateCounter-1)
+				ahead := float64(swing) + float64(l.Gait.Length()-l.Gait.SwingLength(i))/2
 
-		state.Pose.Heading = l.lastPose.Heading + (r * rr)
+				pose := state.Pose
+				pose.Position = *pose.Position.Add(v.MultiplyByScalar(ahead))
+				pose.Heading += turn * ahead
 
-		// Update the Y goal (distance from ground) of each foot according to
-		// the precomputed map.
-		for i, _ := range l.Legs {
-			f := l.Gait.Frame(i, l.stateCounter-1)
+				l.lastFeet[i] = l.feet[i]
+				l.nextFeet[i] = l.homeFootPosition(&state.Offset, leg, pose)
+
+				// Don't bother stepping if the foot is already close enough to
+				// the foothold. This is what keeps the hex from stepping on the
+				// spot while standing still.
+				l.lastFeet[i].Y = 0
+				l.nextFeet[i].Y = 0
+				if l.lastFeet[i].Distance(l.nextFeet[i]) < minFootDistance {
+					continue
+				}
+
+				l.swinging[i] = true
+				l.steps[i] += 1
+			}
+
+			if !l.swinging[i] {
+				l.feet[i].Y = 0
+				continue
+			}
 
-			// TODO: Move this to an attribute-- maybe we can just store the
-			//       last position and offsets? Do we even need the targets?
 			vv := l.nextFeet[i].Subtract(l.lastFeet[i])
 			vvv := vv.MultiplyByScalar(f.XZ)
 
 			l.feet[i].Y = stepHeight * f.Y
 			l.feet[i].X = l.lastFeet[i].X + vvv.X
 			l.feet[i].Z = l.lastFeet[i].Z + vvv.Z
+
+			// Touchdown.
+			if f.XZ >= 1 {
+				l.feet[i] = l.nextFeet[i]
+				l.swinging[i] = false
+			}
+		}
+
+		// Note when we were last moving, to stiffen up again immediately.
+		if v != math3d.ZeroVector3 || turn != 0 || l.anySwinging() {
+			l.lastMoved = now
 		}
 
 		// If this is the last tick in the cycle, reset the state such that the
-		// next tick is #1.
+		// next tick is #1. Every step finishes within the cycle, so put down
+		// any foot which is still (barely) in the air. It's then safe to sit
+		// down, if we're shutting down.
 		if l.stateCounter >= l.Gait.Length() {
+			for i := range l.Legs {
+				if l.swinging[i] {
+					l.feet[i] = l.nextFeet[i]
+					l.swinging[i] = false
+				}
+			}
+
+			l.settling = false
+
+			// Hand over to the animation or the manual leg at the end of the
+			// cycle, when all of the feet are on the ground.
 			if state.Shutdown {
 				l.SetState(sSitDown)
+			} else if state.ManualLeg != "" && l.startManual(state) {
+				l.SetState(sManual)
+			} else if state.Frame != nil {
+				log.Info("starting animation")
+				l.SetState(sAnimating)
+				state.Animating = true
 			} else {
 				l.SetState(sStepping)
 			}
 		}
 
+	// While animating, follow the frames until there are no more. The feet end
+	// up back where they started, so we can go straight back to stepping.
+	case sAnimating:
+		if state.Frame == nil {
+			log.Info("finished animation")
+			l.SetState(sStepping)
+			state.Animating = false
+			break
+		}
+
+		l.animate(state.Frame, state)
+
+	// While controlling a leg directly, lean away from it, lift it once that's
+	// stable, and put it back down before going back to stepping.
+	case sManual:
+		swayTarget = l.tickManual(dt, state)
+
 	default:
 		return fmt.Errorf("unknown state: %#v", l.State)
 	}
 
-	// Adjust the clearance if that's gotten off. This is how we stand up, sit
-	// down, and adjust the clearance at runtime.
-	yOffset := math.Max(-yMoveSpeed, math.Min(yMoveSpeed, (state.Target.Position.Y-state.Pose.Position.Y)))
-	if yOffset != 0 {
-		state.Pose.Position.Y += yOffset
+	// The body is frozen while animating, and the goals were set by animate, so
+	// there's nothing more to move.
+	if l.State != sAnimating || l.stateCounter == 0 {
+
+		// Move the clearance towards the target. This is how we stand up, sit
+		// down, and adjust the clearance at runtime. Same for the x/z
+		// orientation.
+		state.Pose.Position.Y = l.height.Update(math3d.Vector3{X: state.Target.Position.Y}, dt).X
+		state.Pose.Bank = l.bank.Update(math3d.Vector3{X: state.Target.Bank}, dt).X
+		state.Pose.Pitch = l.pitch.Update(math3d.Vector3{X: state.Target.Pitch}, dt).X
+
+		// Sway towards the target. This continues even if the step cycle is
+		// being held, which might make it safe to continue.
+		l.moveSway(swayTarget)
+
+		// Publish the stability margin of the current stance.
+		state.Stability = l.stabilityMargin(l.stance(), state)
+
+		// Update the goal of each leg. The manual leg goes wherever it's told.
+		var goals [6]math3d.Vector3
+		local := l.bodyPose(state).ToLocal()
+		for i := range l.Legs {
+			if l.State == sManual && i == l.manual {
+				goals[i] = l.manualGoal(state.ManualFoot, local)
+				continue
+			}
+
+			goals[i] = l.feet[i].MultiplyByMatrix44(local)
+		}
+
+		l.setGoals(goals, state)
 	}
 
-	// Same for the x/z orientation
-	bankOffset := math.Max(-bankMoveSpeed, math.Min(bankMoveSpeed, (state.Target.Bank-state.Pose.Bank)))
-	if bankOffset != 0 {
-		state.Pose.Bank += bankOffset
+	err := l.updateCompliance(now, state)
+	if err != nil {
+		return err
 	}
 
-	pitchOffset := math.Max(-pitchMoveSpeed, math.Min(pitchMoveSpeed, (state.Target.Pitch-state.Pose.Pitch)))
-	if pitchOffset != 0 {
-		state.Pose.Pitch += pitchOffset
+	l.disableFailed(state)
+
+	// Publish any legs which have failed. If there are too many to keep walking,
+	// sit down.
+	state.DisabledLegs = l.disabledReasons()
+	if len(state.DisabledLegs) > maxDisabledLegs && !state.Shutdown {
+		log.Errorf("too many disabled legs (%d); shutting down", len(state.DisabledLegs))
+		state.Shutdown = true
 	}
 
-	// Update the goal of each leg.
-	for i, leg := range l.Legs {
-		pp := l.feet[i].MultiplyByMatrix44(state.Local())
-		err := leg.SetGoal(pp)
-		if err != nil {
-			log.Warnf("%s (while setting goal position)", err)
-			continue
+	return nil
+}
+
+// velocity returns the distance (in the world space) which the origin should
+// move this tick, and the angle (in degrees) which it should turn, to walk
+// towards the target. The origin follows the walk and turn trajectories, so it
+// moves at the same speed (in mm/s and deg/s) whatever the frame rate, but no
+// faster than the current gait can step. Both are zero if the target is close
+// enough, we're shutting down, or a leg was just disabled.
+func (l *Legs) velocity(dt float64, state *hexapod.State) (math3d.Vector3, float64) {
+	if state.Shutdown || l.settling {
+		return l.stop(state)
+	}
+
+	// Ignore Y axis for target and pose; that's adjusted separately.
+	pos := math3d.Vector3{X: state.Pose.Position.X, Z: state.Pose.Position.Z}
+	target := math3d.Vector3{X: state.Target.Position.X, Z: state.Target.Position.Z}
+
+	// Pick up from wherever the origin is, in case something else moved it.
+	if l.walk.Position() != pos || l.turn.Position().X != state.Pose.Heading {
+		l.stop(state)
+	}
+
+	// Don't start walking unless the target is far enough away. Once started,
+	// the trajectories slow down to stop right on it.
+	moving := l.walk.Velocity() != math3d.ZeroVector3 || l.turn.Velocity() != math3d.ZeroVector3
+	if !moving && target.Distance(pos) < minStepDistance && math.Abs(state.Target.Heading-state.Pose.Heading) < minTurnDistance {
+		return l.stop(state)
+	}
+
+	maxStep := maxStepDistance
+	if l.degraded() {
+		maxStep = degradedStepDistance
+	}
+
+	// Walk slower while derated.
+	maxStep *= 1 - state.Derating
+	maxTurn := maxTurnDistance * (1 - state.Derating)
+
+	// The feet can only step so far per cycle, so that limits the speed, too.
+	cycle := float64(l.Gait.Length()) * l.tickTime()
+	l.walk.Limits.Velocity = math.Min(walkLimits.Velocity, maxStep/cycle)
+	l.turn.Limits.Velocity = math.Min(turnLimits.Velocity, maxTurn/cycle)
+
+	p := l.walk.Update(target, dt)
+	h := l.turn.Update(math3d.Vector3{X: state.Target.Heading}, dt).X
+
+	return p.Subtract(pos), h - state.Pose.Heading
+}
+
+// stop brings the walk and turn trajectories to rest at the current pose, so
+// they start from there when we next walk. Returns zero movement, for the
+// convenience of velocity.
+func (l *Legs) stop(state *hexapod.State) (math3d.Vector3, float64) {
+	l.walk.Reset(math3d.Vector3{X: state.Pose.Position.X, Z: state.Pose.Position.Z})
+	l.turn.Reset(math3d.Vector3{X: state.Pose.Heading})
+	return math3d.ZeroVector3, 0
+}
+
+// tickTime returns the (moving average) duration of a tick, in seconds.
+func (l *Legs) tickTime() float64 {
+	if l.tickDuration == 0 {
+		return defaultTickDuration
+	}
+
+	return l.tickDuration
+}
+
+// ticks returns the number of ticks which the given duration (in seconds) takes
+// at the current frame rate.
+func (l *Legs) ticks(d float64) int {
+	return clamp(minTicksPerStep, math.MaxInt32, int(math.Floor((d/l.tickTime())+0.5)))
+}
+
+// putDown puts any foot which is in the air straight down onto the ground,
+// where it is, abandoning its step.
+func (l *Legs) putDown() {
+	for i := range l.Legs {
+		if l.swinging[i] {
+			l.feet[i].Y = 0
+			l.nextFeet[i] = l.feet[i]
+			l.swinging[i] = false
 		}
 	}
+}
 
-	return nil
+// anySwinging returns true if any foot is in the air.
+func (l *Legs) anySwinging() bool {
+	for _, s := range l.swinging {
+		if s {
+			return true
+		}
+	}
+
+	return false
 }
 
 func clamp(min, max, v int) int {
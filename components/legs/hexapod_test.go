@@ -0,0 +1,109 @@
+package legs
+
+import (
+	"testing"
+	"time"
+
+	"github.com/adammck/hexapod"
+	"github.com/adammck/hexapod/math3d"
+	"github.com/adammck/hexapod/trajectory"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStandUpFrameRate(t *testing.T) {
+	for _, fps := range []int{30, 60, 120} {
+		l := standingLegs(t, 40)
+		l.height = trajectory.New(heightLimits)
+		l.ready = true
+		l.SetState(sStandUp)
+
+		state := &hexapod.State{}
+		state.Target.Position.Y = 40
+
+		now := time.Now()
+		var took time.Duration
+		for i := 0; i < fps*5 && l.State == sStandUp; i++ {
+			now = now.Add(time.Second / time.Duration(fps))
+			took += time.Second / time.Duration(fps)
+
+			err := l.Tick(now, state)
+			assert.NoError(t, err)
+		}
+
+		// Standing up takes about the same time, whatever the frame rate.
+		assert.Equal(t, sStepping, l.State)
+		assert.InDelta(t, 1.1, took.Seconds(), 0.1, "fps=%d", fps)
+	}
+}
+
+func TestWalkFrameRate(t *testing.T) {
+	var dist []float64
+
+	for _, fps := range []int{30, 60, 120} {
+		l, state, tick := steppingLegs(t, fps)
+		l.walk = trajectory.New(walkLimits)
+		state.Target = state.Pose.Add(math3d.Pose{Position: math3d.Vector3{Z: 10000}})
+
+		// Start from standing up, so the ticks have been timed before the gait
+		// is generated, as they would be.
+		l.SetState(sStandUp)
+
+		var z float64
+		for i := 0; i < fps*5; i++ {
+			if i == fps*4 {
+				z = state.Pose.Position.Z
+			}
+
+			tick()
+		}
+
+		// Once up to speed, the hex walks as fast as the tripod gait can step:
+		// 90mm per cycle of two steps, each taking a third of a second.
+		assert.InDelta(t, 135, state.Pose.Position.Z-z, 5, "fps=%d", fps)
+		dist = append(dist, state.Pose.Position.Z)
+	}
+
+	// And covers the same distance in the same time, whatever the frame rate.
+	assert.InDelta(t, dist[0], dist[1], 10)
+	assert.InDelta(t, dist[0], dist[2], 10)
+}
+
+func TestHoldTimeout(t *testing.T) {
+	for _, shutdown := range []bool{false, true} {
+		l, state, tick := steppingLegs(t, 60)
+		state.Target = state.Pose.Add(math3d.Pose{Position: math3d.Vector3{Z: 1000}})
+
+		// No foot can ever be lifted safely.
+		l.MinStabilityMargin = 1000
+
+		// The cycle is held at the first frame which lifts a foot.
+		for i := 0; i < 60; i++ {
+			tick()
+		}
+		assert.Equal(t, sStepping, l.State)
+		assert.False(t, l.heldSince.IsZero())
+		held := l.stateCounter
+
+		tick()
+		assert.Equal(t, held, l.stateCounter)
+
+		// Shutting down while held sits down straight away. Otherwise, it
+		// happens once the hold times out.
+		if shutdown {
+			state.Shutdown = true
+			tick()
+		} else {
+			for i := 0; i < 60*10 && l.State == sStepping; i++ {
+				tick()
+			}
+			assert.True(t, state.Shutdown)
+		}
+
+		assert.Equal(t, sSitDown, l.State, "shutdown=%v", shutdown)
+		assert.False(t, l.anySwinging())
+		for i := 0; i < 60*5 && l.ready; i++ {
+			tick()
+		}
+		assert.False(t, l.ready, "shutdown=%v", shutdown)
+	}
+}
@@ -0,0 +1,70 @@
+package legs
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/adammck/hexapod"
+)
+
+// Handlers returns the HTTP handlers for the legs.
+func (l *Legs) Handlers() map[string]hexapod.HandlerFunc {
+	return map[string]hexapod.HandlerFunc{
+		"/gait":       l.serveGait,
+		"/compliance": l.serveCompliance,
+	}
+}
+
+// serveGait returns the current gait and the names of every available gait as
+// JSON. POSTing a name selects that gait, starting at the next step cycle.
+func (l *Legs) serveGait(w http.ResponseWriter, r *http.Request, state *hexapod.State) {
+	if r.Method == http.MethodPost {
+		name := r.FormValue("name")
+		if _, ok := l.Gaits.Get(name); !ok {
+			http.Error(w, fmt.Sprintf("unknown gait: %q", name), http.StatusBadRequest)
+			return
+		}
+
+		log.Infof("Gait=%s (via HTTP)", name)
+		state.Gait = name
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(struct {
+		Gait  string   `json:"gait"`
+		Gaits []string `json:"gaits"`
+	}{
+		Gait:  state.Gait,
+		Gaits: l.Gaits.Names(),
+	})
+}
+
+// serveCompliance returns the selected compliance profile, the one which is
+// actually in use (which differs if auto is selected), and every profile, as
+// JSON. POSTing a name selects that profile, starting immediately.
+func (l *Legs) serveCompliance(w http.ResponseWriter, r *http.Request, state *hexapod.State) {
+	if r.Method == http.MethodPost {
+		name := r.FormValue("name")
+		if _, ok := profiles[name]; !ok && name != autoCompliance {
+			http.Error(w, fmt.Sprintf("unknown compliance profile: %q", name), http.StatusBadRequest)
+			return
+		}
+
+		log.Infof("Compliance=%s (via HTTP)", name)
+		state.Compliance = name
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(struct {
+		Compliance string             `json:"compliance"`
+		Active     string             `json:"active"`
+		Derating   float64            `json:"derating"`
+		Profiles   map[string]Profile `json:"profiles"`
+	}{
+		Compliance: state.Compliance,
+		Active:     l.profile,
+		Derating:   l.derating,
+		Profiles:   profiles,
+	})
+}
@@ -5,10 +5,8 @@ import (
 	"math"
 
 	"github.com/Sirupsen/logrus"
-	"github.com/adammck/dynamixel/network"
-	"github.com/adammck/dynamixel/servo"
+	"github.com/adammck/hexapod/actuator"
 	"github.com/adammck/hexapod/math3d"
-	"github.com/adammck/hexapod/servos"
 	"github.com/adammck/hexapod/utils"
 )
 
@@ -25,48 +23,72 @@ const (
 	femurLength  = 100.0
 	tibiaLength  = 85.0
 	tarsusLength = 80.5
-
-	// How much extra angle (in degrees) to position the tarsus. This is a hack
-	// to compensate for the amount of mechanical slack in the leg.
-	tarsusExtraAngle = 5
 )
 
 type Leg struct {
 	Name   string
 	Origin *math3d.Vector3
-	Coxa   *servo.Servo
-	Femur  *servo.Servo
-	Tibia  *servo.Servo
-	Tarsus *servo.Servo
+	Coxa   actuator.Actuator
+	Femur  actuator.Actuator
+	Tibia  actuator.Actuator
+	Tarsus actuator.Actuator
 
 	// TODO: Rename this to 'Heading', since that's what it is.
 	Angle float64
+
+	// The angles which the joints were most recently instructed to move to by
+	// SetGoal. Until then, the zero value (legs sticking straight out).
+	Goal Angles
+
+	// Why this leg was disabled, or empty if it wasn't. Disabled legs are held
+	// tucked up out of the way, and the others walk without them.
+	Disabled string
+
+	// The extra angle (in degrees) to rotate the home position of the foot by,
+	// to cover the gap left by a disabled neighbour.
+	spread float64
+
+	// The number of consecutive ticks in which setting the goal has failed.
+	failures int
 }
 
-func NewLeg(network *network.Network, baseId int, name string, origin *math3d.Vector3, angle float64) *Leg {
-	coxa := mustGetServo(network, baseId+1)
-	femur := mustGetServo(network, baseId+2)
-	tibia := mustGetServo(network, baseId+3)
-	tarsus := mustGetServo(network, baseId+4)
+// Angles holds the angle (in degrees) of each joint of a leg, excluding the
+// calibration of the servos.
+type Angles struct {
+	Coxa   float64
+	Femur  float64
+	Tibia  float64
+	Tarsus float64
+}
 
-	return &Leg{
+// NewLeg returns a leg with the four actuators starting at the given base ID,
+// which are created by the given factory. If any of them can't be initialized,
+// the leg is returned disabled, and the missing actuators are nil.
+func NewLeg(f actuator.Factory, baseId int, name string, origin *math3d.Vector3, angle float64) *Leg {
+	leg := &Leg{
 		Origin: origin,
 		Angle:  angle,
 		Name:   name,
-		Coxa:   coxa,
-		Femur:  femur,
-		Tibia:  tibia,
-		Tarsus: tarsus,
 	}
+
+	for i, a := range []*actuator.Actuator{&leg.Coxa, &leg.Femur, &leg.Tibia, &leg.Tarsus} {
+		var err error
+		*a, err = getActuator(f, baseId+i+1)
+		if err != nil && leg.Disabled == "" {
+			leg.Disabled = err.Error()
+		}
+	}
+
+	return leg
 }
 
-func mustGetServo(network *network.Network, ID int) *servo.Servo {
-	s, err := servos.New(network, ID)
+func getActuator(f actuator.Factory, ID int) (actuator.Actuator, error) {
+	a, err := f(ID)
 	if err != nil {
-		panic(err)
+		return nil, fmt.Errorf("%s (while initializing servo #%d)", err, ID)
 	}
 
-	return s
+	return a, nil
 }
 
 // Matrix returns a pointer to a 4x4 matrix, to transform a vector in the leg's
@@ -75,19 +97,37 @@ func (leg *Leg) Matrix() math3d.Matrix44 {
 	return *math3d.MakeMatrix44(*leg.Origin, *math3d.MakeSingularEulerAngle(math3d.RotationHeading, leg.Angle))
 }
 
-// Servos returns an array of all servos attached to this leg.
-func (leg *Leg) Servos() []*servo.Servo {
-	return []*servo.Servo{
-		leg.Coxa,
-		leg.Femur,
-		leg.Tibia,
-		leg.Tarsus,
+// joints returns the actuator of each joint: coxa, femur, tibia, and tarsus.
+// Any which couldn't be initialized are nil.
+func (leg *Leg) joints() [4]actuator.Actuator {
+	return [4]actuator.Actuator{leg.Coxa, leg.Femur, leg.Tibia, leg.Tarsus}
+}
+
+// Actuators returns an array of all actuators attached to this leg, excluding
+// any which couldn't be initialized.
+func (leg *Leg) Actuators() []actuator.Actuator {
+	a := make([]actuator.Actuator, 0, 4)
+
+	for _, aa := range leg.joints() {
+		if aa != nil {
+			a = append(a, aa)
+		}
 	}
+
+	return a
+}
+
+// led is implemented by actuators with an LED, like the AX-12.
+type led interface {
+	SetLED(state bool) error
 }
 
+// SetLED turns on or off the LED of every actuator which has one.
 func (leg *Leg) SetLED(state bool) {
-	for _, s := range leg.Servos() {
-		s.SetLED(state)
+	for _, a := range leg.Actuators() {
+		if l, ok := a.(led); ok {
+			l.SetLED(state)
+		}
 	}
 }
 
@@ -108,41 +148,101 @@ func (leg *Leg) rootSegment() *Segment {
 func (leg *Leg) PresentPosition() (math3d.Vector3, error) {
 	v := math3d.ZeroVector3
 
+	if leg.Disabled != "" {
+		return v, fmt.Errorf("%s leg is disabled: %s", leg.Name, leg.Disabled)
+	}
+
 	coxPos, err := leg.Coxa.Angle()
 	if err != nil {
-		return v, fmt.Errorf("%s (while getting %s coxa (#%d) position)", err, leg.Name, leg.Coxa.ID)
+		return v, fmt.Errorf("%s (while getting %s coxa (#%d) position)", err, leg.Name, leg.Coxa.ID())
 	}
 
 	femPos, err := leg.Femur.Angle()
 	if err != nil {
-		return v, fmt.Errorf("%s (while getting %s femur (#%d) position)", err, leg.Name, leg.Femur.ID)
+		return v, fmt.Errorf("%s (while getting %s femur (#%d) position)", err, leg.Name, leg.Femur.ID())
 	}
 
 	tibPos, err := leg.Tibia.Angle()
 	if err != nil {
-		return v, fmt.Errorf("%s (while getting %s tibia (#%d) position)", err, leg.Name, leg.Tibia.ID)
+		return v, fmt.Errorf("%s (while getting %s tibia (#%d) position)", err, leg.Name, leg.Tibia.ID())
 	}
 
 	tarPos, err := leg.Tarsus.Angle()
 	if err != nil {
-		return v, fmt.Errorf("%s (while getting %s tarsus (#%d) position)", err, leg.Name, leg.Tarsus.ID)
+		return v, fmt.Errorf("%s (while getting %s tarsus (#%d) position)", err, leg.Name, leg.Tarsus.ID())
 	}
 
-	// Remove the extra angle added by SetGoal.
-	tarPos -= tarsusExtraAngle
+	segs := leg.segments(Angles{coxPos, femPos, tibPos, tarPos})
+	return segs[3].End(), nil
+}
 
+// segments returns the coxa, femur, tibia, and tarsus segments (in that order)
+// of this leg, with the joints at the given angles. This is the forward
+// kinematics; the segments project vectors into the hexapod space.
+func (leg *Leg) segments(a Angles) [4]*Segment {
 	root := leg.rootSegment()
-	coxa := MakeSegment("coxa", root, *math3d.MakeSingularEulerAngle(math3d.RotationHeading, coxPos), *math3d.MakeVector3(0, coxaOffsetY, coxaOffsetZ))
-	femur := MakeSegment("femur", coxa, *math3d.MakeSingularEulerAngle(math3d.RotationPitch, femPos), *math3d.MakeVector3(0, 0, femurLength))
-	tibia := MakeSegment("tibia", femur, *math3d.MakeSingularEulerAngle(math3d.RotationPitch, tibPos), *math3d.MakeVector3(0, 0, tibiaLength))
-	tarsus := MakeSegment("tarsus", tibia, *math3d.MakeSingularEulerAngle(math3d.RotationPitch, tarPos), *math3d.MakeVector3(0, 0, tarsusLength))
+	coxa := MakeSegment("coxa", root, *math3d.MakeSingularEulerAngle(math3d.RotationHeading, a.Coxa), *math3d.MakeVector3(0, coxaOffsetY, coxaOffsetZ))
+	femur := MakeSegment("femur", coxa, *math3d.MakeSingularEulerAngle(math3d.RotationPitch, a.Femur), *math3d.MakeVector3(0, 0, femurLength))
+	tibia := MakeSegment("tibia", femur, *math3d.MakeSingularEulerAngle(math3d.RotationPitch, a.Tibia), *math3d.MakeVector3(0, 0, tibiaLength))
+	tarsus := MakeSegment("tarsus", tibia, *math3d.MakeSingularEulerAngle(math3d.RotationPitch, a.Tarsus), *math3d.MakeVector3(0, 0, tarsusLength))
 
-	return tarsus.End(), nil
+	return [4]*Segment{coxa, femur, tibia, tarsus}
 }
 
 // SetGoal sets the goal position of the leg to the given vector in the chassis
 // coordinate space.
 func (leg *Leg) SetGoal(vt math3d.Vector3) error {
+	a, err := leg.solve(vt)
+
+	// Crash if the goal can't be reached. This is of course way too hasty, but
+	// handy for now.
+	if err != nil {
+		panic(err)
+	}
+
+	return leg.SetAngles(a)
+}
+
+// SetAngles sets the goal angle of each joint of the leg. Most callers should
+// use SetGoal instead, unless they want to pose the leg at specific angles.
+func (leg *Leg) SetAngles(a Angles) error {
+
+	// Move the servos! Skip any which are missing, so a disabled leg can still
+	// be tucked out of the way with the rest.
+	err1 := setGoal(leg.Coxa, a.Coxa)
+	err2 := setGoal(leg.Femur, a.Femur)
+	err3 := setGoal(leg.Tibia, a.Tibia)
+	err4 := setGoal(leg.Tarsus, a.Tarsus)
+
+	leg.Goal = a
+
+	if err1 != nil {
+		return err1
+	}
+	if err2 != nil {
+		return err2
+	}
+	if err3 != nil {
+		return err3
+	}
+	if err4 != nil {
+		return err4
+	}
+
+	return nil
+}
+
+func setGoal(a actuator.Actuator, angle float64) error {
+	if a == nil {
+		return nil
+	}
+
+	return a.SetGoal(angle)
+}
+
+// solve returns the joint angles needed to position the end of the leg at the
+// given vector in the chassis coordinate space. This is the inverse kinematics.
+func (leg *Leg) solve(vt math3d.Vector3) (Angles, error) {
 
 	// Solve the angle of the coxa by looking at the position of the target from
 	// above (x,z). Note that "above" here is in the chassis space, which might
@@ -219,7 +319,7 @@ func (leg *Leg) SetGoal(vt math3d.Vector3) error {
 	tibPos := 180 - hh
 	tarPos := 180 - (dd + ee)
 
-	// Crash if any of the angles are invalid.
+	// Check that all of the angles are valid.
 
 	err := false
 
@@ -243,34 +343,14 @@ func (leg *Leg) SetGoal(vt math3d.Vector3) error {
 		err = true
 	}
 
-	// Dump a bunch of debugging info and crash if anything went wrong. This is
-	// of course way too hasty, but handy for now.
+	// Dump a bunch of debugging info if anything went wrong.
 	if err {
 		logrus.Errorf("a=%0.2f, b=%0.2f, c=%0.2f, d=%0.2f, e=%0.2f, f=%0.2f, g=%0.2f", a, b, c, d, e, f, g)
 		logrus.Errorf("aa=%0.2f, bb=%0.2f, cc=%0.2f, dd=%0.2f, ee=%0.2f, hh=%0.2f", aa, bb, cc, dd, ee, hh)
-		panic("goal out of range")
-	}
-
-	// Move the servos!
-	err1 := servos.RegMoveTo(leg.Coxa, coxPos)
-	err2 := servos.RegMoveTo(leg.Femur, femPos)
-	err3 := servos.RegMoveTo(leg.Tibia, tibPos)
-	err4 := servos.RegMoveTo(leg.Tarsus, tarPos+tarsusExtraAngle)
-
-	if err1 != nil {
-		return err1
-	}
-	if err2 != nil {
-		return err2
-	}
-	if err3 != nil {
-		return err3
-	}
-	if err4 != nil {
-		return err4
+		return Angles{}, fmt.Errorf("goal out of range")
 	}
 
-	return nil
+	return Angles{coxPos, femPos, tibPos, tarPos}, nil
 }
 
 // sss returns the angle α, given the length of sides a, b, and c.
@@ -0,0 +1,129 @@
+package legs
+
+import (
+	"math"
+
+	"github.com/adammck/hexapod"
+	"github.com/adammck/hexapod/math3d"
+)
+
+const (
+
+	// The height (in mm) to lift the manual leg's foot before handing it over
+	// to the controller.
+	manualLiftHeight = 50.0
+
+	// The time (in seconds) to take to lift the manual leg, and to put it back.
+	manualLiftTime = 0.5
+)
+
+// legIndex returns the index of the leg with the given name, or -1 if there
+// isn't one.
+func (l *Legs) legIndex(name string) int {
+	for i, leg := range l.Legs {
+		if leg.Name == name {
+			return i
+		}
+	}
+
+	return -1
+}
+
+// startManual selects the leg named in the state to be controlled directly.
+// Returns false (and clears the state) if it can't be.
+func (l *Legs) startManual(state *hexapod.State) bool {
+	n := l.legIndex(state.ManualLeg)
+
+	switch {
+	case n < 0:
+		log.Warnf("can't control unknown leg: %q", state.ManualLeg)
+	case l.Legs[n].Disabled != "":
+		log.Warnf("can't control disabled leg: %s", state.ManualLeg)
+	case l.degraded():
+		log.Warnf("can't control %s leg while another is disabled", state.ManualLeg)
+	default:
+		log.Infof("controlling %s leg", state.ManualLeg)
+		l.manual = n
+		l.manualLift = 0
+		return true
+	}
+
+	state.ManualLeg = ""
+	return false
+}
+
+// manualLifted returns true if the given leg is the manual leg, and its foot
+// has left the ground.
+func (l *Legs) manualLifted(n int) bool {
+	return l.State == sManual && l.manual == n && l.manualLift > 0
+}
+
+// tickManual lifts or lowers the manual leg, and returns the sway which keeps
+// the body stable over the other legs. Once the leg is back down, it switches
+// to the next manual leg (if another was selected) or back to stepping.
+func (l *Legs) tickManual(dt float64, state *hexapod.State) math3d.Vector3 {
+	stance := make([]int, 0, len(l.Legs)-1)
+	for i, leg := range l.Legs {
+		if i != l.manual && leg.Disabled == "" {
+			stance = append(stance, i)
+		}
+	}
+
+	sway := l.swayTowards(stance, state)
+	leg := l.Legs[l.manual]
+
+	// Put the leg down if it's no longer selected, or we're shutting down.
+	if state.Shutdown || state.ManualLeg != leg.Name || leg.Disabled != "" {
+		l.manualLift = math.Max(0, l.manualLift-(dt/manualLiftTime))
+		if l.manualLift > 0 {
+			return sway
+		}
+
+		log.Infof("released %s leg", leg.Name)
+		if !state.Shutdown && state.ManualLeg != "" && l.startManual(state) {
+			return sway
+		}
+
+		l.SetState(sStepping)
+		return sway
+	}
+
+	// Don't lift the leg until the others can hold the body up without it.
+	if l.manualLift == 0 && l.stabilityMargin(stance, state) < l.MinStabilityMargin {
+		if !l.unstable {
+			log.Warnf("delaying lift: stability margin would drop below %0.2fmm", l.MinStabilityMargin)
+			l.unstable = true
+		}
+
+		return sway
+	}
+
+	l.unstable = false
+	l.manualLift = math.Min(1, l.manualLift+(dt/manualLiftTime))
+	return sway
+}
+
+// manualGoal returns the goal (in the hexapod space) of the manual leg's foot,
+// given the offset from its lifted position (in the leg space) and a matrix to
+// transform the world space into the hexapod space. If the foot can't reach,
+// it stays where it was.
+func (l *Legs) manualGoal(offset math3d.Vector3, local math3d.Matrix44) math3d.Vector3 {
+	n := l.manual
+	leg := l.Legs[n]
+
+	// Rotate the offset from the leg space into the hexapod space, but don't
+	// move it; it's relative to the foot, not the leg origin.
+	rot := *math3d.MakeMatrix44(math3d.ZeroVector3, *math3d.MakeSingularEulerAngle(math3d.RotationHeading, leg.Angle))
+	v := *math3d.MakeVector3(0, manualLiftHeight, 0).Add(offset.MultiplyByMatrix44(rot))
+
+	// Ease the foot up and down, so it leaves the ground gently.
+	f := (1 - math.Cos(l.manualLift*math.Pi)) / 2
+	goal := *l.feet[n].MultiplyByMatrix44(local).Add(v.MultiplyByScalar(f))
+
+	_, err := leg.solve(goal)
+	if err != nil {
+		return l.goals[n]
+	}
+
+	return goal
+}
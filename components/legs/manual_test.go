@@ -0,0 +1,67 @@
+package legs
+
+import (
+	"math"
+	"testing"
+
+	"github.com/adammck/hexapod"
+	"github.com/adammck/hexapod/math3d"
+	"github.com/adammck/hexapod/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestManualLeg(t *testing.T) {
+	for n := 0; n < 6; n++ {
+		l, state, tick := steppingLegs(t, 60)
+
+		tick()
+		tick()
+		start := l.goals
+		state.ManualLeg = l.Legs[n].Name
+
+		// The body leans over, then the leg is lifted.
+		for i := 0; i < 300 && l.manualLift < 1; i++ {
+			tick()
+			assert.True(t, state.Stability > 0, "leg=%d, tick=%d, stability=%0.2f", n, i, state.Stability)
+		}
+		assert.Equal(t, sManual, l.State)
+		assert.Equal(t, 1.0, l.manualLift)
+		assert.True(t, state.Stability >= l.MinStabilityMargin, "leg=%d, stability=%0.2f", n, state.Stability)
+		assert.InDelta(t, start[n].Y+manualLiftHeight, l.goals[n].Y, 1e-6)
+
+		// The foot follows the offset, in the leg space, so moves along the leg.
+		lifted := l.goals[n]
+		state.ManualFoot = math3d.Vector3{Z: 20}
+		tick()
+		out := l.goals[n].Subtract(lifted)
+		a := utils.Rad(l.Legs[n].Angle)
+		assert.InDelta(t, 20*math.Sin(a), out.X, 0.01, "leg=%d", n)
+		assert.InDelta(t, 20*math.Cos(a), out.Z, 0.01, "leg=%d", n)
+		lifted = l.goals[n]
+
+		// Unreachable offsets are ignored.
+		state.ManualFoot = math3d.Vector3{Z: 1000}
+		tick()
+		assert.Equal(t, lifted, l.goals[n])
+
+		// Releasing the leg puts it back down, then we go back to stepping.
+		state.ManualLeg = ""
+		for i := 0; i < 100 && l.State == sManual; i++ {
+			tick()
+		}
+		assert.Equal(t, sStepping, l.State)
+		assert.InDelta(t, start[n].Y, l.goals[n].Y, 1e-6)
+	}
+}
+
+func TestManualLegUnknown(t *testing.T) {
+	l := standingLegs(t, 40)
+	state := &hexapod.State{ManualLeg: "XX"}
+	assert.False(t, l.startManual(state))
+	assert.Equal(t, "", state.ManualLeg)
+
+	l.disableLeg(0, "broken")
+	state.ManualLeg = "FR"
+	assert.False(t, l.startManual(state))
+	assert.Equal(t, "", state.ManualLeg)
+}
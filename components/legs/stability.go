@@ -0,0 +1,99 @@
+package legs
+
+import (
+	"github.com/adammck/hexapod"
+	"github.com/adammck/hexapod/components/legs/gait"
+	"github.com/adammck/hexapod/math3d"
+)
+
+const (
+
+	// The default minimum stability margin (in mm). Lifting a foot which would
+	// leave the center of mass closer than this to the edge of the support
+	// polygon is delayed until it's safe.
+	defaultMinStabilityMargin = 10.0
+)
+
+// onGround returns true if the given step height ratio (see gait.Frame.Y) has
+// the foot on the ground.
+func onGround(y float64) bool {
+	return y < gait.LiftThreshold
+}
+
+// stanceAt returns the indices of the legs whose feet are on the ground at the
+// given frame of the current gait, excluding any which are disabled.
+func (l *Legs) stanceAt(n int) []int {
+	s := make([]int, 0, len(l.Legs))
+
+	for i, leg := range l.Legs {
+		if leg.Disabled == "" && onGround(l.Gait.Frame(i, n).Y) {
+			s = append(s, i)
+		}
+	}
+
+	return s
+}
+
+// stance returns the indices of the legs whose feet are currently on the
+// ground, according to their last known positions. Disabled legs and the
+// manual leg (once it's lifted) are never on the ground.
+func (l *Legs) stance() []int {
+	s := make([]int, 0, len(l.Legs))
+
+	for i, leg := range l.Legs {
+		if l.manualLifted(i) {
+			continue
+		}
+
+		if leg.Disabled == "" && onGround(l.feet[i].Y/stepHeight) {
+			s = append(s, i)
+		}
+	}
+
+	return s
+}
+
+// supportPolygon returns the support polygon (in the world space) formed by
+// the feet of the given legs.
+func (l *Legs) supportPolygon(legs []int) math3d.Polygon {
+	pts := make([]math3d.Vector3, len(legs))
+
+	for i, n := range legs {
+		pts[i] = l.feet[n]
+	}
+
+	return math3d.ConvexHull(pts)
+}
+
+// centerOfMass returns the center of mass of the hex in the world space, given
+// the current state.
+func (l *Legs) centerOfMass(state *hexapod.State) math3d.Vector3 {
+	return l.CenterOfMass(l.bodyPose(state))
+}
+
+// stabilityMargin returns the distance (on the X/Z plane) from the projected
+// center of mass to the nearest edge of the support polygon formed by the
+// given legs. Negative values mean that the hex would tip over.
+func (l *Legs) stabilityMargin(legs []int, state *hexapod.State) float64 {
+	return l.supportPolygon(legs).Margin(l.centerOfMass(state))
+}
+
+// safeToLift returns true if the feet which are due to leave the ground at the
+// given frame of the current gait can do so without the stability margin
+// dropping below the minimum. If no feet are being lifted, it's always safe.
+func (l *Legs) safeToLift(n int, state *hexapod.State) bool {
+	lifting := false
+
+	for i, leg := range l.Legs {
+		if leg.Disabled == "" && onGround(l.feet[i].Y/stepHeight) && !onGround(l.Gait.Frame(i, n).Y) {
+			lifting = true
+			break
+		}
+	}
+
+	if !lifting {
+		return true
+	}
+
+	return l.stabilityMargin(l.stanceAt(n), state) >= l.MinStabilityMargin
+}
@@ -0,0 +1,107 @@
+package legs
+
+import (
+	"math"
+
+	"github.com/adammck/hexapod"
+	"github.com/adammck/hexapod/math3d"
+)
+
+const (
+
+	// The maximum speed (in mm per second) which the body may sway at. This
+	// keeps the body moving smoothly, even when the target jumps around at the
+	// start and end of a step cycle.
+	swayMoveSpeed = 120.0
+)
+
+// makeSway precomputes the body sway for every frame of the current gait. The
+// sway for each frame is the offset (in the hexapod space) which moves the
+// center of mass to the center of the support polygon formed by the feet which
+// are on the ground during that frame, assuming that they're at their home
+// positions. This is smoothed out over a step, so the body doesn't lurch each
+// time a foot is lifted.
+func (l *Legs) makeSway() []math3d.Vector3 {
+	n := l.Gait.Length()
+	raw := make([]math3d.Vector3, n)
+
+	var home [6]math3d.Vector3
+	for i, leg := range l.Legs {
+		home[i] = l.homeFootPosition(&math3d.ZeroVector3, leg, math3d.Pose{})
+	}
+
+	com := l.CenterOfMass(math3d.Pose{})
+	com.Y = 0
+
+	for f := 0; f < n; f++ {
+		s := l.stanceAt(f)
+		pts := make([]math3d.Vector3, len(s))
+		for i, idx := range s {
+			pts[i] = home[idx]
+		}
+
+		raw[f] = math3d.ConvexHull(pts).Centroid().Subtract(com)
+	}
+
+	// Don't sway as far while degraded, since the feet are already spread out
+	// to cover the gap, and swaying too far puts them out of reach.
+	if l.degraded() {
+		for f := range raw {
+			if raw[f].Magnitude() > maxDegradedSway {
+				raw[f] = raw[f].Unit().MultiplyByScalar(maxDegradedSway)
+			}
+		}
+	}
+
+	// Smooth with a moving average over a sixth of the cycle (i.e. one step of
+	// the wave gait), wrapping around the ends, since the cycle repeats.
+	w := int(math.Max(1, float64(n/12)))
+	out := make([]math3d.Vector3, n)
+
+	for f := 0; f < n; f++ {
+		var sum math3d.Vector3
+		for o := -w; o <= w; o++ {
+			sum = *sum.Add(raw[(f+o+n)%n])
+		}
+		out[f] = sum.MultiplyByScalar(1 / float64((2*w)+1))
+	}
+
+	return out
+}
+
+// swayTowards returns the sway (in the hexapod space) which would move the
+// center of mass to the center of the support polygon formed by the given legs
+// at their current positions, no further than the maximum degraded sway.
+func (l *Legs) swayTowards(legs []int, state *hexapod.State) math3d.Vector3 {
+	local := state.Pose.ToLocal()
+	c := l.supportPolygon(legs).Centroid().MultiplyByMatrix44(local)
+	com := l.centerOfMass(state).MultiplyByMatrix44(local)
+
+	v := *l.sway.Add(c.Subtract(com))
+	v.Y = 0
+
+	if v.Magnitude() > maxDegradedSway {
+		v = v.Unit().MultiplyByScalar(maxDegradedSway)
+	}
+
+	return v
+}
+
+// moveSway moves the current sway towards the given target, no further than
+// the maximum sway speed allows in the current tick.
+func (l *Legs) moveSway(target math3d.Vector3) {
+	v := target.Subtract(l.sway)
+	max := swayMoveSpeed * l.dt
+
+	if v.Magnitude() > max {
+		v = v.Unit().MultiplyByScalar(max)
+	}
+
+	l.sway = *l.sway.Add(v)
+}
+
+// bodyPose returns the pose of the chassis in the world space, including the
+// offset and any sway.
+func (l *Legs) bodyPose(state *hexapod.State) math3d.Pose {
+	return state.Pose.Add(math3d.Pose{Position: *state.Offset.Add(l.sway)})
+}
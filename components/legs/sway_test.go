@@ -0,0 +1,26 @@
+package legs
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMakeSwayWave(t *testing.T) {
+	l := standingLegs(t, 40)
+	wave, _ := l.Gaits.Get("wave")
+	l.Gait = wave.Gait(20)
+	sway := l.makeSway()
+	assert.Equal(t, l.Gait.Length(), len(sway))
+
+	// While the front left foot is at the top of its step, the body should be
+	// swaying back and to the right, away from it.
+	s := sway[10]
+	assert.True(t, s.X > 0, "expected sway to the right, got %v", s)
+	assert.True(t, s.Z < 0, "expected sway backwards, got %v", s)
+
+	// And the opposite for the back right foot.
+	s = sway[70]
+	assert.True(t, s.X < 0, "expected sway to the left, got %v", s)
+	assert.True(t, s.Z > 0, "expected sway forwards, got %v", s)
+}
@@ -0,0 +1,28 @@
+package monitor
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/adammck/hexapod"
+)
+
+// Handlers returns the HTTP handlers for the monitor.
+func (m *Monitor) Handlers() map[string]hexapod.HandlerFunc {
+	return map[string]hexapod.HandlerFunc{
+		"/servos": m.serveServos,
+		"/buses":  m.serveBuses,
+	}
+}
+
+// serveServos returns the latest status of every servo as JSON, keyed by ID.
+func (m *Monitor) serveServos(w http.ResponseWriter, r *http.Request, state *hexapod.State) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(state.Servos)
+}
+
+// serveBuses returns the traffic on each bus as JSON, keyed by name.
+func (m *Monitor) serveBuses(w http.ResponseWriter, r *http.Request, state *hexapod.State) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(state.Buses)
+}
@@ -0,0 +1,150 @@
+package monitor
+
+import (
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/adammck/hexapod"
+	"github.com/adammck/hexapod/servos"
+)
+
+var log = logrus.WithFields(logrus.Fields{
+	"pkg": "monitor",
+})
+
+const (
+
+	// The time to leave free at the end of each tick, to send the goals.
+	reserve = 2 * time.Millisecond
+
+	// The initial estimate of how long it takes to read a single servo. This is
+	// refined as the reads happen.
+	initialCost = 1 * time.Millisecond
+
+	// If there's no spare time for this long, read a servo anyway, so we don't
+	// lose track of them entirely while the loop is running slowly.
+	maxStarve = 1 * time.Second
+
+	// The number of consecutive times which a servo can fail to answer a status
+	// read before it's published as failed.
+	maxReadFailures = 5
+)
+
+// Monitor reads the status of every servo in the background, a few at a time,
+// in whatever time is left at the end of each tick. The latest status of each
+// is published in State.Servos. Servos in alarm are recovered, and any which
+// can't be, or which stop answering, are published in State.FailedServos. The
+// traffic on each bus is published in State.Buses.
+type Monitor struct {
+	pool *servos.Pool
+	ids  []int
+
+	// The servos which couldn't be recovered, and why.
+	failed map[int]string
+
+	// The number of consecutive failed status reads of each servo, by ID.
+	readFailures map[int]int
+
+	// The duration of each tick, at the target FPS.
+	frame time.Duration
+
+	// The index (in ids) of the next servo to read.
+	next int
+
+	// The (moving average) time taken to read a single servo.
+	cost time.Duration
+
+	// When a servo was last read.
+	last time.Time
+
+	// The time source, and the network which each servo is read via. These are
+	// only replaced by tests.
+	clock func() time.Time
+	rw    func(ID int) io.ReadWriter
+}
+
+// New creates a monitor which reads every servo in the given pool, via whichever
+// bus it's on. It must be added after all of the other components, so it can
+// use the time left after they've all ticked.
+func New(p *servos.Pool, fps int) *Monitor {
+	return &Monitor{
+		pool:         p,
+		ids:          p.IDs(),
+		failed:       map[int]string{},
+		readFailures: map[int]int{},
+		frame:        time.Second / time.Duration(fps),
+		cost:         initialCost,
+		clock:        time.Now,
+		rw: func(ID int) io.ReadWriter {
+			return p.Bus(ID).Network
+		},
+	}
+}
+
+func (m *Monitor) Boot() error {
+	return nil
+}
+
+// Tick reads as many servos as fit in the rest of the tick (but each at most
+// once), continuing from wherever the previous tick left off.
+func (m *Monitor) Tick(now time.Time, state *hexapod.State) error {
+	deadline := now.Add(m.frame - reserve)
+
+	for i := 0; i < len(m.ids); i++ {
+		t := m.clock()
+		if t.Add(m.cost).After(deadline) && (i > 0 || t.Sub(m.last) < maxStarve) {
+			break
+		}
+
+		m.read(m.ids[m.next], t)
+		m.next = (m.next + 1) % len(m.ids)
+
+		d := m.clock().Sub(t)
+		m.cost = ((m.cost * 7) + d) / 8
+		m.last = t
+	}
+
+	state.Servos = m.pool.Status()
+	state.Buses = m.pool.BusStats()
+
+	if len(m.failed) > 0 {
+		state.FailedServos = m.failed
+	}
+
+	return nil
+}
+
+// read reads the status of a single servo into the pool, and tries to recover
+// it if it's in alarm. Errors aren't returned, since a servo which doesn't
+// respond shouldn't stop the others from being monitored. Instead, they're
+// counted, and the servo is failed if it keeps not responding.
+func (m *Monitor) read(ID int, now time.Time) {
+	st, err := m.pool.ReadStatus(m.rw(ID), ID)
+	if err != nil {
+		m.readFailures[ID] += 1
+		n := m.readFailures[ID]
+
+		// Only log the first failure, rather than every time around.
+		if n == 1 {
+			log.Warnf("%s (while reading status of servo #%d)", err, ID)
+		}
+
+		if n == maxReadFailures {
+			m.failed[ID] = fmt.Sprintf("%s (after %d failed status reads)", err, n)
+		}
+
+		return
+	}
+
+	if n, ok := m.readFailures[ID]; ok {
+		log.Infof("servo #%d answered after %d failed status read(s)", ID, n)
+		delete(m.readFailures, ID)
+	}
+
+	err = m.pool.Recover(st, now)
+	if err != nil {
+		m.failed[ID] = err.Error()
+	}
+}
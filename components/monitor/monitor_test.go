@@ -0,0 +1,161 @@
+package monitor
+
+import (
+	"bytes"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/adammck/dynamixel/servo"
+	"github.com/adammck/hexapod"
+	"github.com/adammck/hexapod/servos"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeBus answers every READ_DATA with a status packet, and counts them. Each
+// read advances the clock by a millisecond.
+type fakeBus struct {
+	bytes.Buffer
+	reads []int
+	now   time.Time
+
+	// The error bits which every servo reports.
+	errors servos.ErrorBits
+
+	// If true, the servos don't answer.
+	silent bool
+}
+
+func (b *fakeBus) Write(p []byte) (int, error) {
+	ID := p[2]
+	b.reads = append(b.reads, int(ID))
+	b.now = b.now.Add(time.Millisecond)
+
+	if b.silent {
+		return len(p), nil
+	}
+
+	// Position 512 (zero degrees), load 100 clockwise, 11.1v, 40c.
+	s := []byte{0xFF, 0xFF, ID, 10, byte(b.errors), 0x00, 0x02, 0, 0, 100, 0x04, 111, 40 + ID}
+	var sum byte
+	for _, v := range s[2:] {
+		sum += v
+	}
+
+	b.Buffer.Write(append(s, ^sum))
+	return len(p), nil
+}
+
+func (b *fakeBus) clock() time.Time {
+	return b.now
+}
+
+func (b *fakeBus) rw(ID int) io.ReadWriter {
+	return b
+}
+
+func TestTick(t *testing.T) {
+	bus := &fakeBus{now: time.Unix(0, 0)}
+	state := &hexapod.State{}
+
+	// 20ms ticks, so there's time to read about 17 servos per tick.
+	p := servos.NewPool(servos.NewBus("test", nil))
+	for ID := 1; ID <= 3; ID++ {
+		p.Add(ID, &servo.Servo{ID: ID})
+	}
+
+	m := New(p, 50)
+	m.clock = bus.clock
+	m.rw = bus.rw
+
+	// Each servo is read at most once per tick.
+	err := m.Tick(bus.now, state)
+	assert.NoError(t, err)
+	assert.Equal(t, []int{1, 2, 3}, bus.reads)
+	assert.Len(t, state.Servos, 3)
+
+	s := state.Servos[2]
+	assert.Equal(t, 2, s.ID)
+	assert.InDelta(t, 0, s.Position, 0.01)
+	assert.Equal(t, -100, s.Load)
+	assert.Equal(t, 11.1, s.Voltage)
+	assert.Equal(t, 42, s.Temperature)
+	assert.Equal(t, servos.ErrorBits(0), s.Error)
+
+	// The reads are counted against the bus.
+	assert.Equal(t, 3, state.Buses["test"].Servos)
+	assert.Equal(t, 3, state.Buses["test"].Reads)
+	assert.Equal(t, 0, state.Buses["test"].ReadErrors)
+
+	// With less time left, only some are read, continuing round-robin.
+	bus.reads = nil
+	err = m.Tick(bus.now.Add(-16*time.Millisecond), state)
+	assert.NoError(t, err)
+	assert.Equal(t, []int{1, 2}, bus.reads)
+
+	// With no time left at all, nothing is read...
+	bus.reads = nil
+	err = m.Tick(bus.now.Add(-20*time.Millisecond), state)
+	assert.NoError(t, err)
+	assert.Empty(t, bus.reads)
+
+	// ...unless it's been a while.
+	bus.now = bus.now.Add(maxStarve)
+	err = m.Tick(bus.now.Add(-20*time.Millisecond), state)
+	assert.NoError(t, err)
+	assert.Equal(t, []int{3}, bus.reads)
+}
+
+func TestRecover(t *testing.T) {
+	bus := &fakeBus{now: time.Unix(0, 0), errors: servos.OverloadError}
+	state := &hexapod.State{}
+
+	p := servos.NewPool()
+	p.Add(1, &servo.Servo{ID: 1})
+
+	m := New(p, 50)
+	m.clock = bus.clock
+	m.rw = bus.rw
+
+	// Servos in alarm are recovered a few times, then published as failed.
+	for i := 0; i < 3; i++ {
+		assert.NoError(t, m.Tick(bus.now, state))
+		assert.Empty(t, state.FailedServos)
+	}
+
+	assert.NoError(t, m.Tick(bus.now, state))
+	assert.Contains(t, state.FailedServos[1], "overload")
+}
+
+func TestReadFailures(t *testing.T) {
+	bus := &fakeBus{now: time.Unix(0, 0), silent: true}
+	state := &hexapod.State{}
+
+	p := servos.NewPool()
+	p.Add(1, &servo.Servo{ID: 1})
+
+	m := New(p, 50)
+	m.clock = bus.clock
+	m.rw = bus.rw
+
+	// A servo which stops answering is given a few chances...
+	for i := 1; i < maxReadFailures; i++ {
+		assert.NoError(t, m.Tick(bus.now, state))
+		assert.Empty(t, state.FailedServos)
+	}
+
+	// ...unless it answers in between.
+	bus.silent = false
+	assert.NoError(t, m.Tick(bus.now, state))
+	assert.Empty(t, m.readFailures)
+
+	bus.silent = true
+	for i := 1; i < maxReadFailures; i++ {
+		assert.NoError(t, m.Tick(bus.now, state))
+		assert.Empty(t, state.FailedServos)
+	}
+
+	// Then it's published as failed, so its leg is disabled.
+	assert.NoError(t, m.Tick(bus.now, state))
+	assert.Contains(t, state.FailedServos[1], "failed status reads")
+}
@@ -0,0 +1,59 @@
+package navigator
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/adammck/hexapod"
+)
+
+// Handlers returns the HTTP handlers for the navigator.
+func (n *Navigator) Handlers() map[string]hexapod.HandlerFunc {
+	return map[string]hexapod.HandlerFunc{
+		"/path": n.servePath,
+	}
+}
+
+// servePath returns the current path and the progress along it as JSON. POSTing
+// a JSON array of waypoints replaces the path, and DELETE cancels it, leaving
+// the hex wherever it is.
+func (n *Navigator) servePath(w http.ResponseWriter, r *http.Request, state *hexapod.State) {
+	switch r.Method {
+	case http.MethodPost:
+		var path []Waypoint
+		err := json.NewDecoder(r.Body).Decode(&path)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("invalid path: %s", err), http.StatusBadRequest)
+			return
+		}
+
+		n.SetPath(path)
+
+	case http.MethodDelete:
+		log.Info("path cancelled (via HTTP)")
+		n.SetPath(nil)
+		state.Target.Position.X = state.Pose.Position.X
+		state.Target.Position.Z = state.Pose.Position.Z
+		state.Target.Heading = state.Pose.Heading
+	}
+
+	// The distance to the current waypoint, if there is one.
+	var remaining float64
+	if n.Active() {
+		remaining = distance(n.path[n.current], state)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(struct {
+		Path      []Waypoint `json:"path"`
+		Current   int        `json:"current"`
+		Remaining float64    `json:"remaining"`
+		Complete  bool       `json:"complete"`
+	}{
+		Path:      n.path,
+		Current:   n.current,
+		Remaining: remaining,
+		Complete:  !n.Active(),
+	})
+}
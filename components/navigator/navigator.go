@@ -0,0 +1,153 @@
+package navigator
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/adammck/hexapod"
+	"github.com/adammck/hexapod/utils"
+)
+
+const (
+
+	// The default distance (in mm, on the X/Z plane) within which a waypoint
+	// counts as reached. This must be more than the minimum step distance of
+	// the legs, or they'll stop before getting there.
+	defaultTolerance = 30.0
+
+	// The angle (in degrees) within which the heading of a waypoint counts as
+	// reached. Likewise, this must be more than the minimum turn distance.
+	headingTolerance = 10.0
+)
+
+var log = logrus.WithFields(logrus.Fields{
+	"pkg": "navigator",
+})
+
+// Waypoint is a point in the world space for the origin to walk to. The Y axis
+// is ignored; that's the clearance, which is set separately.
+type Waypoint struct {
+	X float64 `json:"x"`
+	Z float64 `json:"z"`
+
+	// Optional. The heading (in degrees) to turn to at the waypoint. If nil,
+	// the heading is left alone.
+	Heading *float64 `json:"heading,omitempty"`
+
+	// Optional. The distance (in mm) within which the waypoint counts as
+	// reached. If zero, defaultTolerance is used.
+	Tolerance float64 `json:"tolerance,omitempty"`
+}
+
+// Navigator walks the hex along a path of waypoints, by setting the target to
+// each in turn. It must be added after anything else which sets the target
+// (i.e. the controller), so it has the last word while there's a path.
+type Navigator struct {
+	path []Waypoint
+
+	// The index of the waypoint being walked towards. This is equal to the
+	// length of the path once it's complete.
+	current int
+}
+
+func New() *Navigator {
+	return &Navigator{}
+}
+
+func (n *Navigator) Boot() error {
+	return nil
+}
+
+// SetPath replaces the current path (if any) with the given waypoints.
+func (n *Navigator) SetPath(path []Waypoint) {
+	log.Infof("new path with %d waypoints", len(path))
+	n.path = path
+	n.current = 0
+}
+
+// LoadFile replaces the current path with the JSON array of waypoints in the
+// given file.
+func (n *Navigator) LoadFile(filename string) error {
+	f, err := os.Open(filename)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	var path []Waypoint
+	err = json.NewDecoder(f).Decode(&path)
+	if err != nil {
+		return fmt.Errorf("%s (while parsing %s)", err, filename)
+	}
+
+	n.SetPath(path)
+	return nil
+}
+
+// Active returns true if there's a path which hasn't been completed yet.
+func (n *Navigator) Active() bool {
+	return n.current < len(n.path)
+}
+
+func (n *Navigator) Tick(now time.Time, state *hexapod.State) error {
+	if state.Shutdown || !n.Active() {
+		return nil
+	}
+
+	w := n.path[n.current]
+
+	// Move on to the next waypoint once this one has been reached. The target
+	// isn't updated until the next tick, which doesn't matter.
+	if n.reached(w, state) {
+		n.current += 1
+		log.Infof("reached waypoint %d of %d", n.current, len(n.path))
+
+		if !n.Active() {
+			log.Info("path complete")
+		}
+
+		return nil
+	}
+
+	state.Target.Position.X = w.X
+	state.Target.Position.Z = w.Z
+
+	// Turn whichever way is shortest to the heading, since it might be a few
+	// turns away from the current heading.
+	if w.Heading != nil {
+		state.Target.Heading = state.Pose.Heading + utils.AngleDiff(*w.Heading, state.Pose.Heading)
+	} else {
+		state.Target.Heading = state.Pose.Heading
+	}
+
+	return nil
+}
+
+// reached returns true if the origin is close enough to the given waypoint, and
+// facing the right way (if it cares).
+func (n *Navigator) reached(w Waypoint, state *hexapod.State) bool {
+	tol := w.Tolerance
+	if tol == 0 {
+		tol = defaultTolerance
+	}
+
+	if distance(w, state) > tol {
+		return false
+	}
+
+	if w.Heading != nil && math.Abs(utils.AngleDiff(*w.Heading, state.Pose.Heading)) > headingTolerance {
+		return false
+	}
+
+	return true
+}
+
+// distance returns the distance (on the X/Z plane) between the origin and the
+// given waypoint.
+func distance(w Waypoint, state *hexapod.State) float64 {
+	return math.Hypot(w.X-state.Pose.Position.X, w.Z-state.Pose.Position.Z)
+}
@@ -0,0 +1,59 @@
+package navigator
+
+import (
+	"testing"
+	"time"
+
+	"github.com/adammck/hexapod"
+	"github.com/stretchr/testify/assert"
+)
+
+// walk moves the pose of the state towards the target, by at most 10mm and 2
+// degrees, like a very simple hex.
+func walk(state *hexapod.State) {
+	step := func(v, t, max float64) float64 {
+		if t-v > max {
+			return v + max
+		}
+		if v-t > max {
+			return v - max
+		}
+		return t
+	}
+
+	state.Pose.Position.X = step(state.Pose.Position.X, state.Target.Position.X, 10)
+	state.Pose.Position.Z = step(state.Pose.Position.Z, state.Target.Position.Z, 10)
+	state.Pose.Heading = step(state.Pose.Heading, state.Target.Heading, 2)
+}
+
+func TestNavigator(t *testing.T) {
+	h := 90.0
+	n := New()
+	n.SetPath([]Waypoint{
+		{X: 0, Z: 500},
+		{X: 300, Z: 500, Heading: &h},
+		{X: 300, Z: 0, Tolerance: 100},
+	})
+
+	state := &hexapod.State{}
+	state.Pose.Heading = 360
+
+	reached := []int{}
+	for i := 0; i < 500 && n.Active(); i++ {
+		c := n.current
+		assert.NoError(t, n.Tick(time.Now(), state))
+		if n.current != c {
+			reached = append(reached, i)
+		}
+		walk(state)
+	}
+
+	assert.False(t, n.Active())
+	assert.Len(t, reached, 3)
+
+	// The second waypoint is reached turning the short way round (from 360 to
+	// 450, rather than all the way back to 90), and the last one early, because
+	// of the large tolerance.
+	assert.InDelta(t, 450, state.Pose.Heading, headingTolerance)
+	assert.InDelta(t, 100, state.Pose.Position.Z, 10)
+}
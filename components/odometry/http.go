@@ -0,0 +1,33 @@
+package odometry
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/adammck/hexapod"
+	"github.com/adammck/hexapod/math3d"
+)
+
+// Handlers returns the HTTP handlers for the odometry.
+func (o *Odometry) Handlers() map[string]hexapod.HandlerFunc {
+	return map[string]hexapod.HandlerFunc{
+		"/odometry": o.serveOdometry,
+	}
+}
+
+// serveOdometry returns the commanded and estimated poses, and the drift
+// between them, as JSON.
+func (o *Odometry) serveOdometry(w http.ResponseWriter, r *http.Request, state *hexapod.State) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(struct {
+		Commanded    math3d.Pose `json:"commanded"`
+		Estimate     math3d.Pose `json:"estimate"`
+		Drift        float64     `json:"drift"`
+		HeadingDrift float64     `json:"heading_drift"`
+	}{
+		Commanded:    state.Pose,
+		Estimate:     state.Estimate,
+		Drift:        state.Drift,
+		HeadingDrift: state.HeadingDrift,
+	})
+}
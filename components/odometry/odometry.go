@@ -0,0 +1,243 @@
+package odometry
+
+import (
+	"math"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/adammck/hexapod"
+	"github.com/adammck/hexapod/components/legs"
+	"github.com/adammck/hexapod/math3d"
+	"github.com/adammck/hexapod/utils"
+)
+
+const (
+
+	// The default time between measurements. Reading the feet involves reading
+	// every servo in the stance legs, which takes a good chunk of a tick, so we
+	// don't want to do it every tick.
+	defaultInterval = 250 * time.Millisecond
+
+	// The minimum number of feet which must have stayed on the ground between
+	// two measurements to estimate the movement. Two is enough to find the
+	// rotation, but not very reliably.
+	minFeet = 3
+)
+
+var log = logrus.WithFields(logrus.Fields{
+	"pkg": "odometry",
+})
+
+// HasFeet is implemented by legs.Legs.
+type HasFeet interface {
+	StanceFeet() ([]legs.Foot, error)
+	BodyPose(*hexapod.State) math3d.Pose
+}
+
+// HasYaw is implemented by IMUs which can provide the absolute heading (in
+// degrees, clockwise from above, like math3d.Pose.Heading) of the chassis. The
+// zero can be anywhere; only the change is used.
+type HasYaw interface {
+	Yaw() (float64, error)
+}
+
+// Odometry estimates the actual pose of the hex, by measuring how far the body
+// has moved relative to the feet which are on the ground, rather than assuming
+// that every step goes as planned. The estimate is published in the state,
+// along with how far it has drifted from the commanded pose. It's only
+// reported; nothing feeds it back into the commanded pose.
+type Odometry struct {
+	Feet HasFeet
+
+	// Optional. If set, the heading is taken from this rather than estimated
+	// from the feet, which tends to drift less.
+	IMU HasYaw
+
+	// The time between measurements.
+	Interval time.Duration
+
+	// The time of the last measurement.
+	t time.Time
+
+	// The feet which were on the ground at the last measurement, by leg name,
+	// or nil if the last measurement failed.
+	prev map[string]legs.Foot
+
+	// The yaw at the last measurement, if the IMU returned one.
+	prevYaw *float64
+
+	// The estimated pose of the chassis in the world space. This is always
+	// level; the bank and pitch are copied from the commanded pose.
+	body math3d.Pose
+
+	// Set once the first measurement has been taken. Until then, the estimate
+	// is the same as the commanded pose.
+	started bool
+}
+
+func New(f HasFeet) *Odometry {
+	return &Odometry{
+		Feet:     f,
+		Interval: defaultInterval,
+	}
+}
+
+func (o *Odometry) Boot() error {
+	return nil
+}
+
+func (o *Odometry) Tick(now time.Time, state *hexapod.State) error {
+	cmd := o.Feet.BodyPose(state)
+
+	if now.Sub(o.t) >= o.Interval {
+		o.t = now
+		o.measure(cmd)
+	}
+
+	// Until the legs are ready, there's nothing to estimate from. The hex is
+	// probably right where it was told to be, anyway.
+	if !o.started {
+		o.body = math3d.Pose{Position: cmd.Position, Heading: cmd.Heading}
+	}
+
+	o.publish(cmd, state)
+	return nil
+}
+
+// measure reads the stance feet (and the IMU, if there is one), and updates the
+// estimated pose of the body by however far it has moved since the last time.
+// Errors are logged rather than returned, since the estimate isn't critical.
+func (o *Odometry) measure(cmd math3d.Pose) {
+	feet, err := o.Feet.StanceFeet()
+	if err != nil {
+		log.Warnf("%s (while measuring feet)", err)
+		o.prev = nil
+		return
+	}
+
+	if len(feet) == 0 {
+		return
+	}
+
+	var yaw *float64
+	if o.IMU != nil {
+		y, err := o.IMU.Yaw()
+		if err != nil {
+			log.Warnf("%s (while reading yaw)", err)
+		} else {
+			yaw = &y
+		}
+	}
+
+	switch {
+	case !o.started:
+		o.body = math3d.Pose{Position: cmd.Position, Heading: cmd.Heading}
+		o.started = true
+
+	// After a failed measurement, there's nothing to compare the feet to, so
+	// they're only recorded for next time. The estimate so far is kept.
+	case o.prev != nil:
+		var turn *float64
+		if yaw != nil && o.prevYaw != nil {
+			t := utils.AngleDiff(*yaw, *o.prevYaw)
+			turn = &t
+		}
+
+		o.update(feet, turn)
+	}
+
+	o.prev = make(map[string]legs.Foot, len(feet))
+	for _, f := range feet {
+		o.prev[f.Leg] = f
+	}
+
+	o.prevYaw = yaw
+}
+
+// update moves the estimated body pose by the movement relative to the feet
+// which have stayed on the ground since the previous measurement. Since those
+// feet haven't moved, the body must have moved by the opposite. If turn is
+// given, it's used as the change in heading rather than estimating it.
+func (o *Odometry) update(feet []legs.Foot, turn *float64) {
+	var q, r []math3d.Vector3
+
+	for _, f := range feet {
+		p, ok := o.prev[f.Leg]
+		if ok && p.Steps == f.Steps {
+			q = append(q, p.Position)
+			r = append(r, f.Position)
+		}
+	}
+
+	// Always update the height, since it doesn't need the previous positions.
+	// The feet are on the ground, so the body is as far above them as they
+	// are below it.
+	var y float64
+	for _, f := range feet {
+		y -= f.Position.Y / float64(len(feet))
+	}
+	o.body.Position.Y = y
+
+	if len(q) < minFeet {
+		return
+	}
+
+	move := fit(q, r, turn)
+	move.Position.Y = 0
+
+	o.body = o.body.Add(move)
+	o.body.Position.Y = y
+}
+
+// publish updates the state with the estimated pose of the origin, and how far
+// it has drifted from the commanded pose.
+func (o *Odometry) publish(cmd math3d.Pose, state *hexapod.State) {
+
+	// The origin is offset from the body by the same amount in both poses, so
+	// find that offset from the commanded poses and apply it to the estimate.
+	level := math3d.Pose{Position: cmd.Position, Heading: cmd.Heading}
+	off := state.Pose.Position.MultiplyByMatrix44(level.ToLocal())
+
+	est := o.body.Add(math3d.Pose{Position: off})
+	est.Bank = state.Pose.Bank
+	est.Pitch = state.Pose.Pitch
+
+	state.Estimate = est
+	state.Drift = math.Hypot(est.Position.X-state.Pose.Position.X, est.Position.Z-state.Pose.Position.Z)
+	state.HeadingDrift = utils.AngleDiff(est.Heading, state.Pose.Heading)
+}
+
+// fit returns the movement (on the X/Z plane) which best maps the points r onto
+// the points q, i.e. the pose (in the space of q) of the space of r. If turn is
+// given, only the translation is estimated.
+//
+// See: https://en.wikipedia.org/wiki/Procrustes_analysis
+func fit(q, r []math3d.Vector3, turn *float64) math3d.Pose {
+	var qc, rc math3d.Vector3
+	for i := range q {
+		qc = *qc.Add(q[i].MultiplyByScalar(1 / float64(len(q))))
+		rc = *rc.Add(r[i].MultiplyByScalar(1 / float64(len(r))))
+	}
+
+	var h float64
+	if turn != nil {
+		h = utils.Rad(*turn)
+
+	} else {
+		var s, c float64
+		for i := range q {
+			qq := q[i].Subtract(qc)
+			rr := r[i].Subtract(rc)
+			s += (qq.X * rr.Z) - (qq.Z * rr.X)
+			c += (qq.X * rr.X) + (qq.Z * rr.Z)
+		}
+		h = math.Atan2(s, c)
+	}
+
+	// The rotation above is the same as the heading rotation of a pose, so
+	// the translation is whatever's left after rotating the center of r.
+	rot := math3d.Pose{Heading: utils.Deg(h)}
+	t := qc.Subtract(rc.MultiplyByMatrix44(rot.ToWorld()))
+
+	return math3d.Pose{Position: t, Heading: utils.Deg(h)}
+}
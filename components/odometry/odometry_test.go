@@ -0,0 +1,132 @@
+package odometry
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/adammck/hexapod"
+	"github.com/adammck/hexapod/components/legs"
+	"github.com/adammck/hexapod/math3d"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeFeet has four feet fixed in the world space, and measures them relative
+// to the actual pose of the body, which can be different to the commanded pose
+// in the state.
+type fakeFeet struct {
+	world  []math3d.Vector3
+	actual math3d.Pose
+
+	// If set, returned instead of the feet.
+	err error
+}
+
+func (f *fakeFeet) StanceFeet() ([]legs.Foot, error) {
+	if f.err != nil {
+		return nil, f.err
+	}
+
+	feet := make([]legs.Foot, len(f.world))
+	for i, v := range f.world {
+		feet[i] = legs.Foot{
+			Leg:      []string{"A", "B", "C", "D"}[i],
+			Position: v.MultiplyByMatrix44(f.actual.ToLocal()),
+		}
+	}
+	return feet, nil
+}
+
+func (f *fakeFeet) BodyPose(state *hexapod.State) math3d.Pose {
+	return state.Pose
+}
+
+type fakeIMU struct {
+	yaw float64
+}
+
+func (i *fakeIMU) Yaw() (float64, error) {
+	return i.yaw, nil
+}
+
+func TestFit(t *testing.T) {
+	move := math3d.Pose{Position: math3d.Vector3{X: 10, Z: 30}, Heading: 15}
+	q := []math3d.Vector3{{X: -100, Z: 100}, {X: 150, Z: 120}, {X: 0, Z: -200}}
+
+	r := make([]math3d.Vector3, len(q))
+	for i, v := range q {
+		r[i] = v.MultiplyByMatrix44(move.ToLocal())
+	}
+
+	p := fit(q, r, nil)
+	assert.InDelta(t, 10, p.Position.X, 0.01)
+	assert.InDelta(t, 30, p.Position.Z, 0.01)
+	assert.InDelta(t, 15, p.Heading, 0.01)
+}
+
+func TestDrift(t *testing.T) {
+	for _, imu := range []*fakeIMU{nil, {}} {
+		f := &fakeFeet{
+			world: []math3d.Vector3{{X: -150, Z: 150}, {X: 150, Z: 150}, {X: -150, Z: -150}, {X: 150, Z: -150}},
+		}
+		o := New(f)
+		if imu != nil {
+			o.IMU = imu
+		}
+		state := &hexapod.State{}
+
+		now := time.Now()
+		for i := 0; i < 20; i++ {
+			now = now.Add(o.Interval)
+
+			// The body is told to move 5mm forwards each tick, but only manages
+			// 4mm, and turns a little.
+			state.Pose.Position.Z += 5
+			f.actual.Position.Z += 4
+			f.actual.Heading += 0.5
+			f.actual.Position.Y = 40
+			if imu != nil {
+				imu.yaw += 0.5
+			}
+
+			err := o.Tick(now, state)
+			assert.NoError(t, err)
+		}
+
+		// The first measurement is taken as the starting point, so the drift is
+		// over the remaining nineteen.
+		assert.InDelta(t, 19, state.Drift, 1)
+		assert.InDelta(t, 9.5, state.HeadingDrift, 0.01)
+		assert.InDelta(t, 40, state.Estimate.Position.Y, 0.01)
+	}
+}
+
+func TestDriftAfterError(t *testing.T) {
+	f := &fakeFeet{
+		world: []math3d.Vector3{{X: -150, Z: 150}, {X: 150, Z: 150}, {X: -150, Z: -150}, {X: 150, Z: -150}},
+	}
+	o := New(f)
+	state := &hexapod.State{}
+
+	now := time.Now()
+	for i := 0; i < 20; i++ {
+		now = now.Add(o.Interval)
+
+		// As above, but without turning, and a single measurement fails.
+		state.Pose.Position.Z += 5
+		f.actual.Position.Z += 4
+		f.err = nil
+		if i == 10 {
+			f.err = fmt.Errorf("no response")
+		}
+
+		err := o.Tick(now, state)
+		assert.NoError(t, err)
+	}
+
+	// The estimate isn't reset to the commanded pose, so the drift from before
+	// the failure is kept. Only the movement between the last measurement
+	// before the failure and the first one after it is missed.
+	assert.InDelta(t, 5*19-4*17, state.Drift, 0.01)
+	assert.InDelta(t, 0, state.HeadingDrift, 0.01)
+}
@@ -0,0 +1,49 @@
+package protection
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/adammck/hexapod"
+)
+
+// Handlers returns the HTTP handlers for the protection.
+func (p *Protection) Handlers() map[string]hexapod.HandlerFunc {
+	return map[string]hexapod.HandlerFunc{
+		"/protection": p.serveProtection,
+	}
+}
+
+// serveProtection returns the overall level, and the level and recent history
+// of each servo, as JSON. If an id is given, only that servo is returned.
+func (p *Protection) serveProtection(w http.ResponseWriter, r *http.Request, state *hexapod.State) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if v := r.URL.Query().Get("id"); v != "" {
+		ID, err := strconv.Atoi(v)
+		if err != nil {
+			http.Error(w, "invalid id", http.StatusBadRequest)
+			return
+		}
+
+		s, ok := p.servos[ID]
+		if !ok {
+			http.Error(w, "unknown servo", http.StatusNotFound)
+			return
+		}
+
+		json.NewEncoder(w).Encode(s)
+		return
+	}
+
+	json.NewEncoder(w).Encode(struct {
+		Level    Level          `json:"level"`
+		Derating float64        `json:"derating"`
+		Servos   map[int]*servo `json:"servos"`
+	}{
+		Level:    p.level,
+		Derating: state.Derating,
+		Servos:   p.servos,
+	})
+}
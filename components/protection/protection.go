@@ -0,0 +1,243 @@
+package protection
+
+import (
+	"math"
+	"sort"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/adammck/hexapod"
+	"github.com/adammck/hexapod/servos"
+)
+
+var log = logrus.WithFields(logrus.Fields{
+	"pkg": "protection",
+})
+
+// Level is how worried we are about a servo.
+type Level int
+
+const (
+	Normal Level = iota
+	Warning
+	Reduced
+	Critical
+)
+
+func (l Level) String() string {
+	switch l {
+	case Normal:
+		return "normal"
+	case Warning:
+		return "warning"
+	case Reduced:
+		return "reduced"
+	case Critical:
+		return "critical"
+	}
+
+	return "unknown"
+}
+
+func (l Level) MarshalText() ([]byte, error) {
+	return []byte(l.String()), nil
+}
+
+// Thresholds are the values at which each level is entered. A level is only
+// left once the value drops below its threshold by the hysteresis, so we don't
+// flap between levels while hovering around a threshold.
+type Thresholds struct {
+	Warning    float64
+	Reduced    float64
+	Critical   float64
+	Hysteresis float64
+}
+
+var (
+
+	// Temperature, in degrees celsius. The AX-12 shuts itself down at 70c by
+	// default, which we want to stay well clear of.
+	temperature = Thresholds{Warning: 55, Reduced: 60, Critical: 65, Hysteresis: 5}
+
+	// Load, in servo units (of 1023), averaged over loadWindow samples, since
+	// the load spikes briefly whenever a foot lands.
+	load = Thresholds{Warning: 600, Reduced: 800, Critical: 950, Hysteresis: 100}
+)
+
+const (
+
+	// The number of samples to average the load over.
+	loadWindow = 10
+
+	// The number of samples to keep for each servo.
+	historyLength = 300
+
+	// The fraction by which to reduce the torque limit and walking speed while
+	// at the Reduced level.
+	reducedDerating = 0.5
+)
+
+// threshold returns the value at which the given level is entered.
+func (t Thresholds) threshold(l Level) float64 {
+	switch l {
+	case Warning:
+		return t.Warning
+	case Reduced:
+		return t.Reduced
+	case Critical:
+		return t.Critical
+	}
+
+	return math.Inf(-1)
+}
+
+// level returns the level for the given value, having previously been at the
+// given level.
+func (t Thresholds) level(prev Level, v float64) Level {
+	l := Normal
+	for _, ll := range []Level{Warning, Reduced, Critical} {
+		if v >= t.threshold(ll) {
+			l = ll
+		}
+	}
+
+	if l >= prev {
+		return l
+	}
+
+	for prev > l && v < t.threshold(prev)-t.Hysteresis {
+		prev--
+	}
+
+	return prev
+}
+
+// Sample is a single reading from a servo.
+type Sample struct {
+	Time        time.Time `json:"time"`
+	Temperature int       `json:"temperature"`
+	Load        int       `json:"load"`
+}
+
+// servo is the history and level of a single servo.
+type servo struct {
+	Level   Level    `json:"level"`
+	History []Sample `json:"history"`
+
+	tempLevel Level
+	loadLevel Level
+}
+
+// avgLoad returns the average magnitude of the most recent loads.
+func (s *servo) avgLoad() float64 {
+	n := len(s.History)
+	if n > loadWindow {
+		n = loadWindow
+	}
+
+	sum := 0.0
+	for _, h := range s.History[len(s.History)-n:] {
+		sum += math.Abs(float64(h.Load))
+	}
+
+	return sum / float64(n)
+}
+
+// Protection watches the temperature and load of every servo (as read by the
+// monitor), and backs off when they get too high. First it warns, then reduces
+// the torque limit and walking speed, then sits down and shuts down.
+type Protection struct {
+	servos map[int]*servo
+
+	// The highest level of any servo.
+	level Level
+}
+
+func New() *Protection {
+	return &Protection{
+		servos: map[int]*servo{},
+	}
+}
+
+func (p *Protection) Boot() error {
+	return nil
+}
+
+func (p *Protection) Tick(now time.Time, state *hexapod.State) error {
+	level := Normal
+
+	for _, ID := range sortedIDs(state.Servos) {
+		if l := p.update(state.Servos[ID]); l > level {
+			level = l
+		}
+	}
+
+	// Once critical, stay that way; we're already shutting down.
+	if p.level == Critical {
+		return nil
+	}
+
+	if level != p.level {
+		log.Warnf("protection level: %s (was: %s)", level, p.level)
+		p.level = level
+	}
+
+	switch level {
+	case Normal, Warning:
+		state.Derating = 0
+
+	case Reduced:
+		state.Derating = reducedDerating
+
+	case Critical:
+		log.Error("servos are too hot or overloaded; shutting down")
+		state.Shutdown = true
+	}
+
+	return nil
+}
+
+// update records the given status, if it's new, and returns the level of the
+// servo.
+func (p *Protection) update(st servos.Status) Level {
+	s, ok := p.servos[st.ID]
+	if !ok {
+		s = &servo{}
+		p.servos[st.ID] = s
+	}
+
+	n := len(s.History)
+	if n > 0 && !st.Time.After(s.History[n-1].Time) {
+		return s.Level
+	}
+
+	s.History = append(s.History, Sample{Time: st.Time, Temperature: st.Temperature, Load: st.Load})
+	if len(s.History) > historyLength {
+		s.History = s.History[len(s.History)-historyLength:]
+	}
+
+	s.tempLevel = temperature.level(s.tempLevel, float64(st.Temperature))
+	s.loadLevel = load.level(s.loadLevel, s.avgLoad())
+
+	l := s.tempLevel
+	if s.loadLevel > l {
+		l = s.loadLevel
+	}
+
+	if l != s.Level {
+		log.Warnf("servo #%d: %s (temperature=%dc, load=%.0f)", st.ID, l, st.Temperature, s.avgLoad())
+		s.Level = l
+	}
+
+	return l
+}
+
+func sortedIDs(m map[int]servos.Status) []int {
+	ids := make([]int, 0, len(m))
+	for ID := range m {
+		ids = append(ids, ID)
+	}
+
+	sort.Ints(ids)
+	return ids
+}
@@ -0,0 +1,78 @@
+package protection
+
+import (
+	"testing"
+	"time"
+
+	"github.com/adammck/hexapod"
+	"github.com/adammck/hexapod/servos"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLevel(t *testing.T) {
+	th := Thresholds{Warning: 50, Reduced: 60, Critical: 70, Hysteresis: 5}
+
+	assert.Equal(t, Normal, th.level(Normal, 49))
+	assert.Equal(t, Warning, th.level(Normal, 50))
+	assert.Equal(t, Reduced, th.level(Normal, 65))
+	assert.Equal(t, Critical, th.level(Warning, 70))
+
+	// Levels are only left once clear of the hysteresis.
+	assert.Equal(t, Reduced, th.level(Reduced, 56))
+	assert.Equal(t, Warning, th.level(Reduced, 54))
+	assert.Equal(t, Warning, th.level(Warning, 46))
+	assert.Equal(t, Normal, th.level(Warning, 44))
+	assert.Equal(t, Normal, th.level(Reduced, 30))
+}
+
+func TestTick(t *testing.T) {
+	p := New()
+	state := &hexapod.State{}
+	now := time.Unix(0, 0)
+
+	tick := func(temp, load int) {
+		now = now.Add(time.Second)
+		state.Servos = map[int]servos.Status{
+			1: {ID: 1, Temperature: 40, Time: now},
+			2: {ID: 2, Temperature: temp, Load: load, Time: now},
+		}
+
+		err := p.Tick(now, state)
+		assert.NoError(t, err)
+	}
+
+	tick(40, 100)
+	assert.Equal(t, Normal, p.level)
+	assert.Equal(t, 0.0, state.Derating)
+
+	tick(56, 100)
+	assert.Equal(t, Warning, p.level)
+	assert.Equal(t, 0.0, state.Derating)
+
+	tick(61, 100)
+	assert.Equal(t, Reduced, p.level)
+	assert.Equal(t, reducedDerating, state.Derating)
+
+	// Cooling off a little isn't enough.
+	tick(58, 100)
+	assert.Equal(t, Reduced, p.level)
+
+	tick(54, 100)
+	assert.Equal(t, Warning, p.level)
+	assert.Equal(t, 0.0, state.Derating)
+
+	// A single spike of load is averaged out, but a sustained one isn't.
+	tick(40, -1023)
+	assert.Equal(t, Normal, p.level)
+	for i := 0; i < loadWindow; i++ {
+		tick(40, -1000)
+	}
+	assert.Equal(t, Critical, p.level)
+	assert.True(t, state.Shutdown)
+
+	// The same status isn't recorded twice.
+	n := len(p.servos[2].History)
+	p.Tick(now, state)
+	assert.Len(t, p.servos[2].History, n)
+	assert.Len(t, p.servos[1].History, n)
+}
@@ -3,13 +3,12 @@ package hexapod
 import (
 	"fmt"
 	"net/http"
+	"sync"
 	"time"
 
 	"github.com/Sirupsen/logrus"
-	"github.com/adammck/dynamixel/iface"
-	"github.com/adammck/dynamixel/network"
-	proto1 "github.com/adammck/dynamixel/protocol/v1"
 	"github.com/adammck/hexapod/math3d"
+	"github.com/adammck/hexapod/servos"
 	"github.com/adammck/hexapod/utils"
 )
 
@@ -28,6 +27,14 @@ type State struct {
 	// be updated as accurately as possible as the hex walks around.
 	Pose math3d.Pose
 
+	// The pose at the origin estimated by odometry, i.e. where the hex actually
+	// is, as far as we can tell. Pose is where it was told to be, assuming that
+	// every step landed perfectly. The difference between them is the drift;
+	// the distance (in mm) on the X/Z plane, and the heading (in degrees).
+	Estimate     math3d.Pose
+	Drift        float64
+	HeadingDrift float64
+
 	// The offset from the actual home position which the feet should be
 	// positioned at.
 	Offset math3d.Vector3
@@ -41,14 +48,81 @@ type State struct {
 	// pointer so it can be set to nil if there is no target.
 	LookAt *math3d.Vector3
 
-	// The index of the gait which should be used, mod however many gaits are
-	// available. (This doesn't really belong here, but is the simplest way to
+	// The name of the gait which should be used. If empty, the legs will pick
+	// a default. (This doesn't really belong here, but is the simplest way to
 	// pass the selection from the controller to the chassis and I am lazy.)
-	GaitIndex int
+	Gait string
 
 	// The increase (or decrease, if negative) from the default speed at which
 	// we should walk. There is no unit; more is just faster.
 	Speed int
+
+	// The distance (in mm) from the center of mass, projected onto the ground,
+	// to the nearest edge of the support polygon formed by the feet which are
+	// on the ground. Negative if the hex is tipping over.
+	Stability float64
+
+	// The legs which have been disabled (by name), and why. The hex keeps
+	// walking slowly on the rest, if it can.
+	DisabledLegs map[string]string
+
+	// The name of an animation to play. The animation component clears this as
+	// soon as it starts playing it.
+	Animation string
+
+	// The current frame of the animation which is playing, or nil if none is.
+	// When this is set, the legs finish the current step cycle, and then follow
+	// the frames instead of walking, until it's nil again.
+	Frame *Frame
+
+	// Set by the legs while they're following the frames.
+	Animating bool
+
+	// The name of a leg (e.g. "FL") to lift out of the stance and control
+	// directly, or empty to walk on all of them. The legs clear this if the
+	// leg can't be lifted.
+	ManualLeg string
+
+	// The offset (in mm, in the leg space) of the manual leg's foot from the
+	// position it's lifted to. Z points along the leg, and Y points up.
+	ManualFoot math3d.Vector3
+
+	// The contacts (e.g. "FR/MR" or "FL/body") which the legs would have made
+	// during the most recent tick, if they hadn't been stopped.
+	Collisions []string
+
+	// The latest status of each servo (by ID), as read by the monitor. These
+	// are read a few at a time, so may be a fraction of a second old.
+	Servos map[int]servos.Status
+
+	// The traffic on each Dynamixel bus (by name), as counted by the pool.
+	Buses map[string]servos.BusStats
+
+	// The servos (by ID) which are stuck in an alarm that couldn't be cleared,
+	// and why. The legs disable any leg with one of these.
+	FailedServos map[int]string
+
+	// The fraction (from 0 to 1) by which the legs should reduce their torque
+	// limit and walking speed, to give hot or overloaded servos a rest. Zero is
+	// full strength.
+	Derating float64
+
+	// The name of the compliance profile (e.g. "soft") which the legs should
+	// use. If empty or "auto", the legs go soft while standing still, and stiff
+	// while walking.
+	Compliance string
+}
+
+// Frame is a single frame of an animation. It's relative to the pose of the
+// body at the start of the animation, so feet which don't move in the frames
+// stay put on the ground while the body moves.
+type Frame struct {
+
+	// The pose of the body, relative to its pose at the start.
+	Body math3d.Pose
+
+	// The position of each foot, in the hexapod space at the start.
+	Feet [6]math3d.Vector3
 }
 
 // World returns a matrix to transform a vector in the coordinate space defined
@@ -66,13 +140,16 @@ func (s *State) Local() math3d.Matrix44 {
 }
 
 type Hexapod struct {
-	Network    *network.Network // TODO: Make this a io.ReadWriter
 	Components []Component
 
-	// Keep an unbound (i.e. having no particular servo ID) protocol for each
-	// version present on the Dynamixel network. This is just v1, so long as
-	// we're only using AX-12s.
-	Protocols []iface.Protocol
+	// The servos, on one or more Dynamixel buses. Components which drive
+	// servos should create them via the pool, so they're all powered off at
+	// shutdown.
+	Pool *servos.Pool
+
+	// Held for the duration of each tick, and while handling HTTP requests, so
+	// the HTTP handlers can safely read and update the state.
+	mu sync.Mutex
 
 	// Most components receive (and update) the state every tick, to instruct or
 	// react to instructions. This is more easily testable than passing around
@@ -95,14 +172,23 @@ type Component interface {
 	Tick(time.Time, *State) error
 }
 
-// NewHexapod creates a new Hexapod object on the given Dynamixel network.
-func NewHexapod(network *network.Network, targetFPS int) *Hexapod {
+// HandlerFunc is like http.HandlerFunc, but also receives the state. It's only
+// called between ticks, so can safely read and update it.
+type HandlerFunc func(http.ResponseWriter, *http.Request, *State)
+
+// HasHandlers is implemented by components which expose an HTTP interface. The
+// keys are the paths to serve each handler at.
+type HasHandlers interface {
+	Handlers() map[string]HandlerFunc
+}
+
+// NewHexapod creates a new Hexapod object on the given Dynamixel buses. The
+// first is the default, for servos which the robot description doesn't put on a
+// particular one.
+func NewHexapod(buses servos.Buses, targetFPS int) *Hexapod {
 	return &Hexapod{
-		Network:    network,
 		Components: []Component{},
-		Protocols: []iface.Protocol{
-			proto1.New(network),
-		},
+		Pool:       servos.NewPool(buses...),
 		State: &State{
 			FPS: 0,
 			Pose: math3d.Pose{
@@ -114,9 +200,9 @@ func NewHexapod(network *network.Network, targetFPS int) *Hexapod {
 				Position: math3d.ZeroVector3,
 				Heading:  0,
 			},
-			LookAt:    nil,
-			GaitIndex: 0,
-			Speed:     0,
+			LookAt: nil,
+			Gait:   "",
+			Speed:  0,
 		},
 		TargetFPS: targetFPS,
 		fc:        utils.NewFrameCounter(time.Second),
@@ -138,7 +224,10 @@ func (h *Hexapod) Boot() error {
 	}
 
 	// Trigger any buffered instructions written during boot.
-	h.ActionInstruction()
+	err := h.ActionInstruction()
+	if err != nil {
+		return fmt.Errorf("%s (while sending ACTION)", err)
+	}
 
 	return nil
 }
@@ -151,10 +240,11 @@ var log = logrus.WithFields(logrus.Fields{
 // trigger any buffered instructions.
 func (h *Hexapod) Tick(now time.Time) error {
 
-	// Lock the network during tick. Any other goroutines wanting to hit the
-	// network (e.g. legs.waitForReady) must first acquire the lock.
-	h.Network.Lock()
-	defer h.Network.Unlock()
+	// The buses aren't locked here: the pool locks each one around its own I/O,
+	// so other goroutines (e.g. legs.waitForReady) can use them between reads.
+	// But block the HTTP handlers, so they don't see a half-updated state.
+	h.mu.Lock()
+	defer h.mu.Unlock()
 
 	// Update the fps counter.
 	h.fc.Frame(now)
@@ -175,21 +265,22 @@ func (h *Hexapod) Tick(now time.Time) error {
 		}
 	}
 
-	// Trigger any buffered instructions written during this tick.
-	h.ActionInstruction()
+	// Trigger any buffered instructions written during this tick. If that fails,
+	// don't give up; the servos on the failed bus report it when they're next
+	// given a goal, so the legs can decide what to do without them.
+	err := h.ActionInstruction()
+	if err != nil {
+		log.Debugf("%s (while sending ACTION)", err)
+	}
 
 	return nil
 }
 
+// ActionInstruction sends any goals which have been batched up for a single
+// SYNC_WRITE, then the ACTION instruction of each protocol, to execute any which
+// were buffered with REG_WRITE instead. Each bus is written to concurrently.
 func (h *Hexapod) ActionInstruction() error {
-	for i := range h.Protocols {
-		err := h.Protocols[i].Action()
-		if err != nil {
-			return err
-		}
-	}
-
-	return nil
+	return h.Pool.Action()
 }
 
 // TODO: Move this stuff to a separate package.
@@ -199,7 +290,8 @@ var log2 = logrus.WithFields(logrus.Fields{
 })
 
 // Remote starts an HTTP server which can update the configuration. It blocks
-// forever, so start it in a goroutine.
+// forever, so start it in a goroutine, after all of the components have been
+// added.
 func (h *Hexapod) RunServer(port int) {
 	indexHTML := ""
 
@@ -208,8 +300,30 @@ func (h *Hexapod) RunServer(port int) {
 		fmt.Fprintf(w, "<pre>%s</pre>", indexHTML)
 	})
 
+	for _, c := range h.Components {
+		hh, ok := c.(HasHandlers)
+		if !ok {
+			continue
+		}
+
+		for path, fn := range hh.Handlers() {
+			indexHTML += fmt.Sprintf("<a href=\"%s\">%s</a>\n", path, path)
+			http.HandleFunc(path, h.wrapHandler(fn))
+		}
+	}
+
 	addr := fmt.Sprintf(":%d", port)
 	log2.Infof("listening on %s", addr)
 	err := http.ListenAndServe(addr, nil)
 	panic(err)
 }
+
+// wrapHandler returns an http.HandlerFunc which calls the given handler with
+// the state, while holding the lock.
+func (h *Hexapod) wrapHandler(fn HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		h.mu.Lock()
+		defer h.mu.Unlock()
+		fn(w, r, h.State)
+	}
+}
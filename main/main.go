@@ -6,9 +6,15 @@ import (
 	log "github.com/Sirupsen/logrus"
 	"github.com/adammck/dynamixel/network"
 	"github.com/adammck/hexapod"
+	"github.com/adammck/hexapod/actuator"
+	"github.com/adammck/hexapod/components/animation"
 	"github.com/adammck/hexapod/components/controller"
 	"github.com/adammck/hexapod/components/head"
 	"github.com/adammck/hexapod/components/legs"
+	"github.com/adammck/hexapod/components/monitor"
+	"github.com/adammck/hexapod/components/navigator"
+	"github.com/adammck/hexapod/components/odometry"
+	"github.com/adammck/hexapod/components/protection"
 	"io"
 	"io/ioutil"
 	"os"
@@ -25,12 +31,22 @@ import (
 )
 
 var (
-	serialPort     = flag.String("serial-port", "/dev/ttyACM0", "path to the serial port")
+	serialPort     = flag.String("serial-port", "/dev/ttyACM0", "path to the serial port, or a list of name=path for several buses (e.g. left=/dev/ttyACM0,right=/dev/ttyACM1)")
 	controllerPort = flag.String("controller-port", "/dev/input/event1", "path to the sixaxis controller")
 	debug          = flag.Bool("debug", false, "enable verbose logging")
 	httpPort       = flag.Int("http-port", 8000, "port to start HTTP server on")
 	offline        = flag.Bool("offline", false, "run in offline mode (with fake devices)")
 	fps            = flag.Int("fps", 60, "set the number of frames per second")
+	minStability   = flag.Float64("min-stability", 10, "minimum stability margin (in mm) to allow a foot to be lifted")
+	sway           = flag.Bool("sway", false, "sway the body towards the support polygon while stepping")
+	gaitName       = flag.String("gait", "wave", "name of the gait to start with")
+	gaitDir        = flag.String("gaits", "", "path to a directory of custom gait definitions, to add to (or replace) the built-in ones")
+	odo            = flag.Bool("odometry", false, "estimate the actual pose from the measured positions of the feet")
+	pathFile       = flag.String("path", "", "path to a JSON file of waypoints to walk along")
+	calFile        = flag.String("calibration", "calibration.json", "path to the servo calibration file")
+	robotFile      = flag.String("robot", "robot.json", "path to the robot description, which declares the model of each servo")
+	animDir        = flag.String("animations", "", "path to a directory of animations")
+	syncWrite      = flag.Bool("sync-write", true, "send all goals in a single SYNC_WRITE each tick, rather than REG_WRITE and ACTION")
 )
 
 func main() {
@@ -41,63 +57,111 @@ func main() {
 		log.SetLevel(log.DebugLevel)
 	}
 
-	sOpts := serial.OpenOptions{
-		PortName:              *serialPort,
-		BaudRate:              1000000,
-		DataBits:              8,
-		StopBits:              1,
-		MinimumReadSize:       0,
-		InterCharacterTimeout: 100,
+	ports, err := servos.ParsePorts(*serialPort)
+	if err != nil {
+		log.Fatalf("error parsing serial ports: %s", err)
 	}
 
-	var srl io.ReadWriteCloser
-	if *offline {
-		log.Warn("using fake serial port")
-		srl = &fake_serial.FakeSerial{}
+	// Each bus (i.e. USB2AX) gets its own network, so they can be written to
+	// concurrently.
+	var buses servos.Buses
+	for _, port := range ports {
+		var srl io.ReadWriteCloser
+		if *offline {
+			log.Warnf("using fake serial port for %s bus", port.Name)
+			srl = &fake_serial.FakeSerial{}
 
-	} else {
-		log.Info("opening serial port")
-		srl, err = serial.Open(sOpts)
-		if err != nil {
-			log.Fatalf("error opening serial port: %s\n", err)
+		} else {
+			log.Infof("opening serial port for %s bus: %s", port.Name, port.Path)
+			srl, err = serial.Open(serial.OpenOptions{
+				PortName:              port.Path,
+				BaudRate:              1000000,
+				DataBits:              8,
+				StopBits:              1,
+				MinimumReadSize:       0,
+				InterCharacterTimeout: 100,
+			})
+			if err != nil {
+				log.Fatalf("error opening serial port: %s\n", err)
+			}
+			defer srl.Close()
+
+			var b []byte
+			log.Info("purging serial buffer")
+			b, err = ioutil.ReadAll(srl)
+			if err != nil {
+				log.Fatalf("error purging serial buffer: %s\n", err)
+			}
+			log.Infof("purged %d bytes", len(b))
 		}
-		defer srl.Close()
 
-		var b []byte
-		log.Info("purging serial buffer")
-		b, err = ioutil.ReadAll(srl)
-		if err != nil {
-			log.Fatalf("error purging serial buffer: %s\n", err)
+		n := network.New(srl)
+		n.Timeout = 1 * time.Second
+
+		// Optionally log network traffic. This is VERY verbose!
+		if *debug {
+			n.Logger = log.WithFields(log.Fields{
+				"pkg": "dxl",
+				"bus": port.Name,
+			})
 		}
-		log.Infof("purged %d bytes", len(b))
+
+		buses = append(buses, servos.NewBus(port.Name, n))
 	}
 
-	network := network.New(srl)
-	network.Timeout = 1 * time.Second
+	h := hexapod.NewHexapod(buses, *fps)
+	h.Pool.SyncWrite = *syncWrite
 
-	// Optionally log network traffic. This is VERY verbose!
-	if *debug {
-		network.Logger = log.WithFields(log.Fields{
-			"pkg": "dxl",
-		})
+	// Load the description and calibration before creating any components,
+	// since they may move servos as soon as they're created.
+	err = h.Pool.LoadDescription(*robotFile)
+	if os.IsNotExist(err) {
+		log.Infof("no robot description at %s (assuming all servos are AX-12s)", *robotFile)
+	} else if err != nil {
+		log.Fatalf("error loading robot description: %s", err)
 	}
 
-	h := hexapod.NewHexapod(network, *fps)
+	err = h.Pool.LoadCalibration(*calFile)
+	if err != nil {
+		log.Warnf("error loading calibration: %s (servos will be uncalibrated)", err)
+	}
 
 	log.Infof("initializing loop at %dfps", *fps)
 	ticker := time.NewTicker(time.Duration(1000000000 / *fps))
 
-	if *httpPort > 0 {
-		log.Info("starting HTTP interface")
-		go h.RunServer(*httpPort)
-	} else {
-		log.Warn("HTTP interface disabled")
+	// Offline, the joints are simulated rather than driven over the (fake)
+	// network, so they actually move.
+	var factory actuator.Factory = h.Pool.Actuator
+	if *offline {
+		log.Warn("using simulated actuators")
+		factory = actuator.SimFactory
 	}
 
 	log.Info("creating components")
-	l := legs.New(network)
+	l := legs.New(factory)
+	l.JointSpeeds = *syncWrite
+	l.MinStabilityMargin = *minStability
+	l.Sway = *sway
 	h.Add(l)
 
+	if *gaitDir != "" {
+		err = l.Gaits.LoadDir(*gaitDir)
+		if err != nil {
+			log.Fatalf("error loading gaits: %s", err)
+		}
+	}
+
+	if _, ok := l.Gaits.Get(*gaitName); !ok {
+		log.Fatalf("unknown gait: %s (try one of: %v)", *gaitName, l.Gaits.Names())
+	}
+	h.State.Gait = *gaitName
+
+	// Odometry reads a lot of servos, and the fake serial port only responds to
+	// pings, so there's no point running it offline.
+	if *odo && !*offline {
+		h.Add(odometry.New(l))
+	}
+
 	var f *os.File
 	if *offline {
 		log.Warn("using fake controller")
@@ -112,29 +176,72 @@ func main() {
 		}
 		defer f.Close()
 	}
-	h.Add(controller.New(f))
+	anim := animation.New(l)
+	if *animDir != "" {
+		err = anim.LoadDir(*animDir)
+		if err != nil {
+			log.Fatalf("error loading animations: %s", err)
+		}
+	}
+
+	ctrl := controller.New(f)
+	ctrl.Gaits = l.Gaits.Names()
+	ctrl.Animations = anim.Names()
+	ctrl.Compliances = legs.ComplianceNames()
+	h.Add(ctrl)
+
+	// The navigator must come after the controller, since both set the target.
+	nav := navigator.New()
+	if *pathFile != "" {
+		err = nav.LoadFile(*pathFile)
+		if err != nil {
+			log.Fatalf("error loading path: %s", err)
+		}
+	}
+	h.Add(nav)
+
+	// The animator must come after the controller and navigator, since it
+	// overrides the head target while playing.
+	h.Add(anim)
 
 	var v voltage.HasVoltage
 	if *offline {
 		log.Warn("using fake voltage check")
 		v = fake_voltage.New(9.6)
 	} else {
-		v = l.Legs[0].Coxa
+		as := l.Actuators()
+		if len(as) == 0 {
+			log.Fatal("no leg servos available for voltage check")
+		}
+		v = as[0]
 	}
 	h.Add(voltage.New(v))
 
-	headH, err := servos.New(network, 71)
+	headH, err := factory(71)
 	if err != nil {
 		log.Fatalf("error while initializing servo #71: %s", err)
 	}
-	headV, err := servos.New(network, 72)
+	headV, err := factory(72)
 	if err != nil {
 		log.Fatalf("error while initializing servo #72: %s", err)
 	}
-	h.Add(head.New(
-		math3d.Pose{math3d.Vector3{X: 0, Y: 43.0, Z: 70}, 0, 0, 0},
-		headH,
-		headV))
+	headPose := math3d.Pose{math3d.Vector3{X: 0, Y: 43.0, Z: 70}, 0, 0, 0}
+	h.Add(head.New(headPose, headH, headV))
+	l.Payload = append(l.Payload, legs.PointMass{Position: headPose.Position, Mass: head.Mass})
+
+	// The monitor uses the time left at the end of each tick, so must be added
+	// last. Like odometry, it can't do anything useful with the fake serial.
+	if !*offline {
+		h.Add(protection.New())
+		h.Add(monitor.New(h.Pool, *fps))
+	}
+
+	if *httpPort > 0 {
+		log.Info("starting HTTP interface")
+		go h.RunServer(*httpPort)
+	} else {
+		log.Warn("HTTP interface disabled")
+	}
 
 	log.Info("booting components")
 	err = h.Boot()
@@ -160,7 +267,7 @@ func main() {
 	defer func() {
 		if r := recover(); r != nil {
 			log.Warnf("recovered from panic: %s", r)
-			servos.Shutdown()
+			h.Pool.Shutdown()
 			os.Exit(1)
 		}
 	}()
@@ -198,7 +305,7 @@ func main() {
 		if time.Since(shutdownPending) > gracePeriod {
 			log.Warn("done waiting, shutting down")
 			ticker.Stop()
-			servos.Shutdown()
+			h.Pool.Shutdown()
 			break
 		}
 	}
@@ -0,0 +1,83 @@
+package math3d
+
+import (
+	"math"
+)
+
+// Capsule is a line segment with a radius, i.e. a cylinder with rounded ends.
+// It's a cheap approximation of the volume of a limb.
+type Capsule struct {
+	A      Vector3
+	B      Vector3
+	Radius float64
+}
+
+// Clearance returns the distance between the surfaces of two capsules, or the
+// depth of the overlap (as a negative number) if they intersect.
+func (c Capsule) Clearance(cc Capsule) float64 {
+	return segmentsDistance(c.A, c.B, cc.A, cc.B) - c.Radius - cc.Radius
+}
+
+// segmentsDistance returns the shortest distance between the line segments
+// p1-q1 and p2-q2.
+//
+// See: Ericson, Real-Time Collision Detection, section 5.1.9.
+func segmentsDistance(p1, q1, p2, q2 Vector3) float64 {
+	const epsilon = 1e-9
+
+	d1 := q1.Subtract(p1)
+	d2 := q2.Subtract(p2)
+	r := p1.Subtract(p2)
+	a := d1.Dot(d1)
+	e := d2.Dot(d2)
+	f := d2.Dot(r)
+
+	var s, t float64
+
+	switch {
+
+	// Both segments are points.
+	case a <= epsilon && e <= epsilon:
+		return r.Magnitude()
+
+	// The first segment is a point.
+	case a <= epsilon:
+		t = clamp01(f / e)
+
+	default:
+		c := d1.Dot(r)
+
+		// The second segment is a point.
+		if e <= epsilon {
+			s = clamp01(-c / a)
+			break
+		}
+
+		// Find the closest point on the first line to the second line. If
+		// they're parallel, any point will do, so pick the start.
+		b := d1.Dot(d2)
+		denom := (a * e) - (b * b)
+		if denom > epsilon {
+			s = clamp01(((b * f) - (c * e)) / denom)
+		}
+
+		// Then the closest point on the second segment to that, clamping both
+		// if it's off either end.
+		t = ((b * s) + f) / e
+		if t < 0 {
+			t = 0
+			s = clamp01(-c / a)
+		} else if t > 1 {
+			t = 1
+			s = clamp01((b - c) / a)
+		}
+	}
+
+	c1 := p1.Add(d1.MultiplyByScalar(s))
+	c2 := p2.Add(d2.MultiplyByScalar(t))
+	return c1.Distance(*c2)
+}
+
+func clamp01(v float64) float64 {
+	return math.Max(0, math.Min(1, v))
+}
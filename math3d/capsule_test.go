@@ -0,0 +1,48 @@
+package math3d
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSegmentsDistance(t *testing.T) {
+	examples := []struct {
+		p1, q1, p2, q2 Vector3
+		exp            float64
+	}{
+		// Crossing, one above the other.
+		{Vector3{-1, 0, 0}, Vector3{1, 0, 0}, Vector3{0, 2, -1}, Vector3{0, 2, 1}, 2},
+
+		// Parallel, overlapping.
+		{Vector3{0, 0, 0}, Vector3{4, 0, 0}, Vector3{2, 0, 3}, Vector3{6, 0, 3}, 3},
+
+		// Parallel, end to end.
+		{Vector3{0, 0, 0}, Vector3{1, 0, 0}, Vector3{3, 0, 0}, Vector3{5, 0, 0}, 2},
+
+		// Closest at an end of one segment.
+		{Vector3{0, 0, 0}, Vector3{0, 0, 1}, Vector3{-1, 0, 3}, Vector3{1, 0, 3}, 2},
+
+		// Touching.
+		{Vector3{0, 0, 0}, Vector3{2, 2, 0}, Vector3{2, 0, 0}, Vector3{0, 2, 0}, 0},
+
+		// Points.
+		{Vector3{1, 1, 1}, Vector3{1, 1, 1}, Vector3{1, 1, 4}, Vector3{1, 1, 4}, 3},
+		{Vector3{0, 0, 0}, Vector3{0, 0, 0}, Vector3{-1, 2, 0}, Vector3{1, 2, 0}, 2},
+		{Vector3{-1, 2, 0}, Vector3{1, 2, 0}, Vector3{0, 0, 0}, Vector3{0, 0, 0}, 2},
+	}
+
+	for _, x := range examples {
+		assert.InDelta(t, x.exp, segmentsDistance(x.p1, x.q1, x.p2, x.q2), 1e-9, "%v-%v, %v-%v", x.p1, x.q1, x.p2, x.q2)
+		assert.InDelta(t, x.exp, segmentsDistance(x.p2, x.q2, x.p1, x.q1), 1e-9, "%v-%v, %v-%v", x.p2, x.q2, x.p1, x.q1)
+	}
+}
+
+func TestCapsuleClearance(t *testing.T) {
+	a := Capsule{Vector3{0, 0, 0}, Vector3{10, 0, 0}, 2}
+	b := Capsule{Vector3{5, 5, -10}, Vector3{5, 5, 10}, 2}
+	assert.InDelta(t, 1, a.Clearance(b), 1e-9)
+
+	b.Radius = 4
+	assert.InDelta(t, -1, a.Clearance(b), 1e-9)
+}
@@ -0,0 +1,146 @@
+package math3d
+
+import (
+	"math"
+	"sort"
+)
+
+// Polygon is a convex polygon on the X/Z plane (i.e. the ground), with its
+// vertices in counter-clockwise order when viewed from above. The Y component
+// of each vertex is ignored.
+type Polygon []Vector3
+
+// ConvexHull returns the convex hull of the given points, projected onto the
+// X/Z plane. This is the support polygon, if the points are feet.
+//
+// See: https://en.wikibooks.org/wiki/Algorithm_Implementation/Geometry/Convex_hull/Monotone_chain
+func ConvexHull(points []Vector3) Polygon {
+	if len(points) < 3 {
+		p := make(Polygon, len(points))
+		copy(p, points)
+		return p
+	}
+
+	ps := make([]Vector3, len(points))
+	copy(ps, points)
+	sort.Slice(ps, func(i, j int) bool {
+		if ps[i].X == ps[j].X {
+			return ps[i].Z < ps[j].Z
+		}
+		return ps[i].X < ps[j].X
+	})
+
+	hull := make(Polygon, 0, len(ps)*2)
+
+	// Lower hull
+	for _, p := range ps {
+		for len(hull) >= 2 && cross(hull[len(hull)-2], hull[len(hull)-1], p) <= 0 {
+			hull = hull[:len(hull)-1]
+		}
+		hull = append(hull, p)
+	}
+
+	// Upper hull
+	t := len(hull) + 1
+	for i := len(ps) - 2; i >= 0; i-- {
+		p := ps[i]
+		for len(hull) >= t && cross(hull[len(hull)-2], hull[len(hull)-1], p) <= 0 {
+			hull = hull[:len(hull)-1]
+		}
+		hull = append(hull, p)
+	}
+
+	// The last point is the same as the first.
+	return hull[:len(hull)-1]
+}
+
+// Margin returns the distance from the given point (projected onto the X/Z
+// plane) to the nearest edge of the polygon. This is positive if the point is
+// inside the polygon, and negative if it's outside. Polygons with fewer than
+// three vertices have no inside, so the margin is never positive.
+func (p Polygon) Margin(v Vector3) float64 {
+	switch len(p) {
+	case 0:
+		return math.Inf(-1)
+
+	case 1:
+		return -flatDistance(p[0], v)
+
+	case 2:
+		return -segmentDistance(p[0], p[1], v)
+	}
+
+	m := math.Inf(1)
+	for i := range p {
+		a := p[i]
+		b := p[(i+1)%len(p)]
+
+		l := flatDistance(a, b)
+		if l == 0 {
+			continue
+		}
+
+		d := cross(a, b, v) / l
+		if d < m {
+			m = d
+		}
+	}
+
+	return m
+}
+
+// Centroid returns the center of the area of the polygon, on the X/Z plane.
+// Degenerate polygons (fewer than three vertices) return the mean of their
+// vertices instead.
+func (p Polygon) Centroid() Vector3 {
+	if len(p) == 0 {
+		return ZeroVector3
+	}
+
+	var a, cx, cz float64
+	for i := range p {
+		v0 := p[i]
+		v1 := p[(i+1)%len(p)]
+		c := (v0.X * v1.Z) - (v1.X * v0.Z)
+		a += c
+		cx += (v0.X + v1.X) * c
+		cz += (v0.Z + v1.Z) * c
+	}
+
+	if a == 0 {
+		var mean Vector3
+		for _, v := range p {
+			mean.X += v.X / float64(len(p))
+			mean.Z += v.Z / float64(len(p))
+		}
+		return mean
+	}
+
+	return Vector3{X: cx / (3 * a), Z: cz / (3 * a)}
+}
+
+// cross returns the Z component of the cross product of the vectors OA and OB
+// on the X/Z plane. This is positive if OAB makes a counter-clockwise turn.
+func cross(o, a, b Vector3) float64 {
+	return ((a.X - o.X) * (b.Z - o.Z)) - ((a.Z - o.Z) * (b.X - o.X))
+}
+
+// flatDistance returns the distance between two vectors on the X/Z plane.
+func flatDistance(a, b Vector3) float64 {
+	return math.Hypot(b.X-a.X, b.Z-a.Z)
+}
+
+// segmentDistance returns the distance between the point v and the line
+// segment AB, on the X/Z plane.
+func segmentDistance(a, b, v Vector3) float64 {
+	dx := b.X - a.X
+	dz := b.Z - a.Z
+	l2 := (dx * dx) + (dz * dz)
+	if l2 == 0 {
+		return flatDistance(a, v)
+	}
+
+	t := (((v.X - a.X) * dx) + ((v.Z - a.Z) * dz)) / l2
+	t = math.Max(0, math.Min(1, t))
+	return flatDistance(Vector3{X: a.X + (t * dx), Z: a.Z + (t * dz)}, v)
+}
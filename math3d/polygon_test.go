@@ -0,0 +1,67 @@
+package math3d
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestConvexHull(t *testing.T) {
+	hull := ConvexHull([]Vector3{
+		{X: 0, Y: 0, Z: 0},
+		{X: 10, Y: 5, Z: 0},
+		{X: 5, Y: 0, Z: 5},
+		{X: 10, Y: 0, Z: 10},
+		{X: 0, Y: 0, Z: 10},
+	})
+
+	// The point in the middle is discarded.
+	assert.Equal(t, 4, len(hull))
+	assert.Equal(t, Vector3{X: 0, Y: 0, Z: 0}, hull[0])
+	assert.Equal(t, Vector3{X: 10, Y: 5, Z: 0}, hull[1])
+	assert.Equal(t, Vector3{X: 10, Y: 0, Z: 10}, hull[2])
+	assert.Equal(t, Vector3{X: 0, Y: 0, Z: 10}, hull[3])
+}
+
+func TestMargin(t *testing.T) {
+	square := ConvexHull([]Vector3{
+		{X: 0, Z: 0},
+		{X: 10, Z: 0},
+		{X: 10, Z: 10},
+		{X: 0, Z: 10},
+	})
+
+	type eg struct {
+		poly Polygon
+		v    Vector3
+		out  float64
+	}
+
+	examples := []eg{
+		{square, Vector3{X: 5, Y: 99, Z: 5}, 5},
+		{square, Vector3{X: 2, Z: 5}, 2},
+		{square, Vector3{X: 10, Z: 5}, 0},
+		{square, Vector3{X: 12, Z: 5}, -2},
+		{Polygon{{X: 0, Z: 0}, {X: 10, Z: 0}}, Vector3{X: 5, Z: 3}, -3},
+		{Polygon{{X: 0, Z: 0}}, Vector3{X: 3, Z: 4}, -5},
+	}
+
+	for i, x := range examples {
+		assert.InDelta(t, x.out, x.poly.Margin(x.v), 0.01, "example %d", i+1)
+	}
+}
+
+func TestCentroid(t *testing.T) {
+	tri := ConvexHull([]Vector3{
+		{X: 0, Z: 0},
+		{X: 6, Z: 0},
+		{X: 0, Z: 6},
+	})
+
+	c := tri.Centroid()
+	assert.InDelta(t, 2, c.X, 0.01)
+	assert.InDelta(t, 2, c.Z, 0.01)
+
+	c = Polygon{{X: 0, Z: 0}, {X: 4, Z: 2}}.Centroid()
+	assert.InDelta(t, 2, c.X, 0.01)
+	assert.InDelta(t, 1, c.Z, 0.01)
+}
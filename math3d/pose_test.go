@@ -14,29 +14,29 @@ func TestAdd(t *testing.T) {
 
 	examples := []eg{
 		{
-			recv: Pose{Vector3{+0, +0, +0}, 0},
-			arg:  Pose{Vector3{+0, +0, +0}, 0},
-			out:  Pose{Vector3{+0, +0, +0}, 0},
+			recv: Pose{Vector3{+0, +0, +0}, 0, 0, 0},
+			arg:  Pose{Vector3{+0, +0, +0}, 0, 0, 0},
+			out:  Pose{Vector3{+0, +0, +0}, 0, 0, 0},
 		},
 		{
-			recv: Pose{Vector3{+0, +0, +0}, 90},
-			arg:  Pose{Vector3{+1, +0, +0}, 0},
-			out:  Pose{Vector3{+0, +0, -1}, 90},
+			recv: Pose{Vector3{+0, +0, +0}, 90, 0, 0},
+			arg:  Pose{Vector3{+1, +0, +0}, 0, 0, 0},
+			out:  Pose{Vector3{+0, +0, -1}, 90, 0, 0},
 		},
 		{
-			recv: Pose{Vector3{+0, +0, +0}, 180},
-			arg:  Pose{Vector3{+1, +0, +0}, 0},
-			out:  Pose{Vector3{-1, +0, +0}, 180},
+			recv: Pose{Vector3{+0, +0, +0}, 180, 0, 0},
+			arg:  Pose{Vector3{+1, +0, +0}, 0, 0, 0},
+			out:  Pose{Vector3{-1, +0, +0}, 180, 0, 0},
 		},
 		{
-			recv: Pose{Vector3{+0, +0, +0}, 270},
-			arg:  Pose{Vector3{+1, +0, +0}, 0},
-			out:  Pose{Vector3{+0, +0, +1}, 270},
+			recv: Pose{Vector3{+0, +0, +0}, 270, 0, 0},
+			arg:  Pose{Vector3{+1, +0, +0}, 0, 0, 0},
+			out:  Pose{Vector3{+0, +0, +1}, 270, 0, 0},
 		},
 		{
-			recv: Pose{Vector3{+9, +1, +9}, 90},
-			arg:  Pose{Vector3{+1, +0, +0}, 90},
-			out:  Pose{Vector3{+9, +1, +8}, 180},
+			recv: Pose{Vector3{+9, +1, +9}, 90, 0, 0},
+			arg:  Pose{Vector3{+1, +0, +0}, 90, 0, 0},
+			out:  Pose{Vector3{+9, +1, +8}, 180, 0, 0},
 		},
 	}
 
@@ -58,9 +58,9 @@ func TestOut(t *testing.T) {
 
 	examples := []eg{
 		{
-			recv: Pose{Vector3{+0, +0, -8}, 90},
-			arg:  Pose{Vector3{+0, +0, -9}, 0},
-			out:  Pose{Vector3{+1, +0, +0}, -90},
+			recv: Pose{Vector3{+0, +0, -8}, 90, 0, 0},
+			arg:  Pose{Vector3{+0, +0, -9}, 0, 0, 0},
+			out:  Pose{Vector3{+1, +0, +0}, -90, 0, 0},
 		},
 	}
 
@@ -74,6 +74,11 @@ func (v Vector3) Unit() Vector3 {
 	}
 }
 
+// Dot returns the dot product of this vector and another.
+func (v Vector3) Dot(vv Vector3) float64 {
+	return (v.X * vv.X) + (v.Y * vv.Y) + (v.Z * vv.Z)
+}
+
 // MultiplyByMatrix44 returns a new Vector3, by multiplying this vector my a 4x4
 // matrix.
 func (v Vector3) MultiplyByMatrix44(m Matrix44) Vector3 {
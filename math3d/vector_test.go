@@ -77,3 +77,9 @@ func TestMultiplyByScalar(t *testing.T) {
 	vExp = Vector3{X: 2, Y: 4, Z: 6}
 	assert.Equal(t, vExp, vAct)
 }
+
+func TestDot(t *testing.T) {
+	assert.Equal(t, 0.0, Vector3{X: 1}.Dot(Vector3{Z: 1}))
+	assert.Equal(t, 32.0, Vector3{1, 2, 3}.Dot(Vector3{4, 5, 6}))
+	assert.Equal(t, -14.0, Vector3{1, 2, 3}.Dot(Vector3{-1, -2, -3}))
+}
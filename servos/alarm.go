@@ -0,0 +1,158 @@
+package servos
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+// ErrorBits is the error byte of an AX-12 status packet.
+type ErrorBits byte
+
+const (
+	InputVoltageError ErrorBits = 1 << iota
+	AngleLimitError
+	OverheatingError
+	RangeError
+	ChecksumError
+	OverloadError
+	InstructionError
+)
+
+var errorNames = []string{
+	"input voltage",
+	"angle limit",
+	"overheating",
+	"range",
+	"checksum",
+	"overload",
+	"instruction",
+}
+
+func (e ErrorBits) String() string {
+	var s []string
+	for i, name := range errorNames {
+		if e&(1<<uint(i)) != 0 {
+			s = append(s, name)
+		}
+	}
+
+	if len(s) == 0 {
+		return "none"
+	}
+
+	return strings.Join(s, ", ")
+}
+
+// Alarm returns true if any of the errors which stop the servo from moving
+// until its torque is re-enabled are set.
+func (e ErrorBits) Alarm() bool {
+	return e&(OverloadError|OverheatingError|AngleLimitError) != 0
+}
+
+const (
+
+	// The number of times to try to recover a servo from an alarm, before
+	// giving up on it.
+	maxRecoveries = 3
+)
+
+type recovery struct {
+	attempts int
+
+	// When recovery was last attempted.
+	last time.Time
+}
+
+// Recover attempts to bring a servo out of alarm, if the given status says that
+// it's in one, by rebooting it (if it speaks protocol 2), resetting its torque
+// limit, re-enabling its torque, and re-sending its goal. Each attempt waits
+// for a status read after the previous one, to see whether it worked. An error
+// is returned once the servo has been recovered too many times without success.
+func (p *Pool) Recover(st Status, now time.Time) error {
+	s, ok := p.Get(st.ID)
+	if !ok {
+		return nil
+	}
+
+	p.alarmMu.Lock()
+	defer p.alarmMu.Unlock()
+
+	r, ok := p.recoveries[st.ID]
+	if ok && !st.Time.After(r.last) {
+		return nil
+	}
+
+	if !st.Error.Alarm() {
+		if ok {
+			log.Infof("servo #%d recovered after %d attempt(s)", st.ID, r.attempts)
+			delete(p.recoveries, st.ID)
+		}
+
+		return nil
+	}
+
+	if !ok {
+		r = &recovery{}
+		p.recoveries[st.ID] = r
+	}
+
+	if r.attempts >= maxRecoveries {
+		return fmt.Errorf("servo #%d alarm: %s (gave up after %d recoveries)", st.ID, st.Error, r.attempts)
+	}
+
+	r.attempts += 1
+	r.last = now
+	log.Warnf("servo #%d alarm: %s (recovery attempt %d)", st.ID, st.Error, r.attempts)
+
+	err := p.reset(s, st.ID)
+	if err != nil {
+		return err
+	}
+
+	p.calMu.Lock()
+	angle, ok := p.lastAngle[st.ID]
+	p.calMu.Unlock()
+
+	if ok {
+		err = p.RegMoveTo(st.ID, angle)
+		if err != nil {
+			return fmt.Errorf("%s (while re-sending goal)", err)
+		}
+	}
+
+	return nil
+}
+
+// reset reboots the given servo (if it speaks protocol 2), then resets its
+// torque limit and re-enables its torque, with its bus locked.
+func (p *Pool) reset(s Servo, ID int) error {
+	limit := p.torqueLimit(ID)
+	defer p.lock(ID)()
+
+	// Protocol 2 servos keep their hardware error, with the torque disabled,
+	// until they're rebooted.
+	if p.Model(ID).Protocol == 2 {
+		x, ok := s.(*xl430)
+		if ok {
+			err := x.Reboot()
+			if err != nil {
+				return fmt.Errorf("%s (while rebooting)", err)
+			}
+		}
+	}
+
+	err := s.SetTorqueLimit(limit)
+	if err != nil {
+		return fmt.Errorf("%s (while resetting torque limit)", err)
+	}
+
+	err = s.SetTorqueEnable(true)
+	if err != nil {
+		return fmt.Errorf("%s (while enabling torque)", err)
+	}
+
+	return nil
+}
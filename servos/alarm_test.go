@@ -0,0 +1,61 @@
+package servos
+
+import (
+	"testing"
+	"time"
+
+	"github.com/adammck/dynamixel/network"
+	"github.com/adammck/hexapod/fake/serial"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestErrorBits(t *testing.T) {
+	assert.Equal(t, "none", ErrorBits(0).String())
+	assert.Equal(t, "overheating, overload", (OverheatingError | OverloadError).String())
+
+	assert.False(t, ErrorBits(0).Alarm())
+	assert.False(t, (InputVoltageError | ChecksumError).Alarm())
+	assert.True(t, OverloadError.Alarm())
+	assert.True(t, (AngleLimitError | InputVoltageError).Alarm())
+}
+
+func TestRecover(t *testing.T) {
+	n := network.New(&serial.FakeSerial{})
+	p := NewPool(NewBus("test", n))
+	p.SyncWrite = true
+
+	s := fakeServo(t, n, 1)
+	p.Add(1, s)
+	p.RegMoveTo(1, 30)
+	p.Flush(&serial.FakeSerial{})
+
+	now := time.Unix(0, 0)
+	status := func(e ErrorBits) Status {
+		now = now.Add(time.Second)
+		return Status{ID: 1, Error: e, Time: now}
+	}
+
+	// Servos which are fine are left alone.
+	assert.NoError(t, p.Recover(status(0), now))
+	assert.Empty(t, p.recoveries)
+
+	// The goal is re-sent while recovering.
+	st := status(OverloadError)
+	assert.NoError(t, p.Recover(st, now))
+	assert.Equal(t, 1, p.recoveries[1].attempts)
+	assert.Equal(t, AX12.position(30), p.pending[1].position)
+
+	// Nothing happens until the status has been read again.
+	assert.NoError(t, p.Recover(st, now))
+	assert.Equal(t, 1, p.recoveries[1].attempts)
+
+	// Give up after a few attempts.
+	for i := 1; i < maxRecoveries; i++ {
+		assert.NoError(t, p.Recover(status(OverloadError), now))
+	}
+	assert.Error(t, p.Recover(status(OverloadError), now))
+
+	// Once it comes back, the budget is reset.
+	assert.NoError(t, p.Recover(status(0), now))
+	assert.Empty(t, p.recoveries)
+}
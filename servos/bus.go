@@ -0,0 +1,270 @@
+package servos
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/adammck/dynamixel/iface"
+	"github.com/adammck/dynamixel/network"
+	proto1 "github.com/adammck/dynamixel/protocol/v1"
+	proto2 "github.com/adammck/dynamixel/protocol/v2"
+)
+
+// Bus is a single Dynamixel network (i.e. a USB2AX), with the servos on it. The
+// hex can have several, which are written to concurrently.
+type Bus struct {
+	Name    string
+	Network *network.Network
+
+	// Keep an unbound (i.e. having no particular servo ID) protocol for each
+	// version which might be on the bus: v1 for the AX-12s, and v2 for the
+	// X-series servos. Each only understands its own ACTION.
+	Protocols []iface.Protocol
+
+	statsMu sync.Mutex
+	stats   BusStats
+
+	// The error from the most recent flush, if it failed. SYNC_WRITEs aren't
+	// acknowledged, so this is all we know about whether the goals arrived.
+	err error
+}
+
+// BusStats counts the traffic on a bus.
+type BusStats struct {
+
+	// The number of servos on the bus.
+	Servos int `json:"servos"`
+
+	// The number of flushes, and the packets and bytes written by them.
+	Flushes int `json:"flushes"`
+	Packets int `json:"packets"`
+	Bytes   int `json:"bytes"`
+
+	// The (moving average) time taken to flush, including the ACTIONs.
+	FlushTime time.Duration `json:"flush_time"`
+
+	// The number of status reads, and how many of them failed.
+	Reads      int `json:"reads"`
+	ReadErrors int `json:"read_errors"`
+
+	// The number of flushes which failed.
+	Errors int `json:"errors"`
+}
+
+// NewBus returns a bus with the given name, on the given network.
+func NewBus(name string, n *network.Network) *Bus {
+	return &Bus{
+		Name:    name,
+		Network: n,
+		Protocols: []iface.Protocol{
+			proto1.New(n),
+			proto2.New(n),
+		},
+	}
+}
+
+// protocol returns the protocol of the given version (1 or 2) on this bus.
+func (b *Bus) protocol(version int) iface.Protocol {
+	return b.Protocols[version-1]
+}
+
+// flush writes the given packets to the bus, followed by the ACTION of each
+// protocol, to execute anything which was buffered with REG_WRITE. The network
+// is locked meanwhile.
+func (b *Bus) flush(packets [][]byte) error {
+	start := time.Now()
+	n := 0
+
+	err := func() error {
+		b.Network.Lock()
+		defer b.Network.Unlock()
+
+		for _, p := range packets {
+			_, err := b.Network.Write(p)
+			if err != nil {
+				return fmt.Errorf("%s (while writing SYNC_WRITE)", err)
+			}
+			n += len(p)
+		}
+
+		for _, p := range b.Protocols {
+			err := p.Action()
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}()
+
+	b.statsMu.Lock()
+	defer b.statsMu.Unlock()
+
+	b.stats.Flushes += 1
+	b.stats.Packets += len(packets)
+	b.stats.Bytes += n
+	b.stats.FlushTime = ((b.stats.FlushTime * 7) + time.Since(start)) / 8
+	if err != nil {
+		b.stats.Errors += 1
+	}
+
+	b.err = err
+	return err
+}
+
+// flushErr returns the error from the most recent flush, if it failed.
+func (b *Bus) flushErr() error {
+	b.statsMu.Lock()
+	defer b.statsMu.Unlock()
+
+	if b.err != nil {
+		return fmt.Errorf("%s (while flushing %s bus)", b.err, b.Name)
+	}
+
+	return nil
+}
+
+// countRead records a status read, and whether it failed.
+func (b *Bus) countRead(err error) {
+	b.statsMu.Lock()
+	defer b.statsMu.Unlock()
+
+	b.stats.Reads += 1
+	if err != nil {
+		b.stats.ReadErrors += 1
+	}
+}
+
+// Buses is every bus on the hex. The first is the default, for any servos which
+// the robot description doesn't put on a particular one.
+type Buses []*Bus
+
+// get returns the bus with the given name, or nil.
+func (bs Buses) get(name string) *Bus {
+	for _, b := range bs {
+		if b.Name == name {
+			return b
+		}
+	}
+
+	return nil
+}
+
+// Bus returns the bus which the given servo is on, according to the robot
+// description, or nil if there are no buses.
+func (p *Pool) Bus(ID int) *Bus {
+	p.mu.Lock()
+	name, ok := p.busNames[ID]
+	p.mu.Unlock()
+
+	if ok {
+		return p.Buses.get(name)
+	}
+
+	if len(p.Buses) == 0 {
+		return nil
+	}
+
+	return p.Buses[0]
+}
+
+// lock locks the network of the bus which the given servo is on, so that its
+// I/O doesn't interleave with any other goroutine's, and returns a function to
+// unlock it. Only the servo's own bus is locked, so the others can be read and
+// written to meanwhile.
+func (p *Pool) lock(ID int) func() {
+	b := p.Bus(ID)
+	if b == nil || b.Network == nil {
+		return func() {}
+	}
+
+	b.Network.Lock()
+	return b.Network.Unlock
+}
+
+// BusStats returns the stats of every bus, by name.
+func (p *Pool) BusStats() map[string]BusStats {
+	m := make(map[string]BusStats, len(p.Buses))
+	for _, b := range p.Buses {
+		b.statsMu.Lock()
+		m[b.Name] = b.stats
+		b.statsMu.Unlock()
+	}
+
+	for _, ID := range p.IDs() {
+		if b := p.Bus(ID); b != nil {
+			st := m[b.Name]
+			st.Servos += 1
+			m[b.Name] = st
+		}
+	}
+
+	return m
+}
+
+// Action flushes the goals and compliance settings collected since the previous
+// Flush or Action to each bus, then sends the ACTIONs to execute anything which
+// was buffered with REG_WRITE. The buses are independent, so are written to
+// concurrently.
+func (p *Pool) Action() error {
+	errs := make([]error, len(p.Buses))
+
+	var wg sync.WaitGroup
+	for i, b := range p.Buses {
+		packets := p.packets(func(ID int) bool {
+			return p.Bus(ID) == b
+		})
+
+		wg.Add(1)
+		go func(i int, b *Bus) {
+			defer wg.Done()
+			errs[i] = b.flush(packets)
+		}(i, b)
+	}
+
+	wg.Wait()
+
+	for i, err := range errs {
+		if err != nil {
+			return fmt.Errorf("%s (while flushing %s bus)", err, p.Buses[i].Name)
+		}
+	}
+
+	return nil
+}
+
+// Port is the name and serial port of a bus.
+type Port struct {
+	Name string
+	Path string
+}
+
+// ParsePorts parses a list of serial ports, e.g. "left=/dev/ttyACM0,right=/dev/
+// ttyACM1", into the name and path of each bus, in order. A single path with no
+// name (the usual case) is a bus named "main".
+func ParsePorts(s string) ([]Port, error) {
+	if !strings.Contains(s, "=") {
+		return []Port{{"main", s}}, nil
+	}
+
+	var ports []Port
+	seen := map[string]bool{}
+
+	for _, f := range strings.Split(s, ",") {
+		kv := strings.SplitN(f, "=", 2)
+		if len(kv) != 2 || kv[0] == "" || kv[1] == "" {
+			return nil, fmt.Errorf("invalid serial port: %q (expected name=path)", f)
+		}
+
+		if seen[kv[0]] {
+			return nil, fmt.Errorf("duplicate bus name: %q", kv[0])
+		}
+
+		seen[kv[0]] = true
+		ports = append(ports, Port{kv[0], kv[1]})
+	}
+
+	return ports, nil
+}
@@ -0,0 +1,157 @@
+package servos
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/adammck/dynamixel/network"
+	"github.com/adammck/dynamixel/servo"
+	"github.com/adammck/hexapod/fake/serial"
+	"github.com/stretchr/testify/assert"
+)
+
+// recordingSerial is a fake serial port which keeps everything written to it,
+// unless it's broken.
+type recordingSerial struct {
+	serial.FakeSerial
+	written bytes.Buffer
+	broken  bool
+}
+
+func (s *recordingSerial) Write(p []byte) (int, error) {
+	if s.broken {
+		return 0, errors.New("broken")
+	}
+
+	return s.written.Write(p)
+}
+
+func TestParsePorts(t *testing.T) {
+	ps, err := ParsePorts("/dev/ttyACM0")
+	assert.NoError(t, err)
+	assert.Equal(t, []Port{{"main", "/dev/ttyACM0"}}, ps)
+
+	ps, err = ParsePorts("left=/dev/ttyACM0,right=/dev/ttyACM1")
+	assert.NoError(t, err)
+	assert.Equal(t, []Port{{"left", "/dev/ttyACM0"}, {"right", "/dev/ttyACM1"}}, ps)
+
+	_, err = ParsePorts("left=/dev/ttyACM0,/dev/ttyACM1")
+	assert.Error(t, err)
+
+	_, err = ParsePorts("left=/dev/ttyACM0,left=/dev/ttyACM1")
+	assert.Error(t, err)
+}
+
+func TestBus(t *testing.T) {
+	left := NewBus("left", network.New(&serial.FakeSerial{}))
+	right := NewBus("right", network.New(&serial.FakeSerial{}))
+	p := NewPool(left, right)
+
+	fn := filepath.Join(t.TempDir(), "robot.json")
+	os.WriteFile(fn, []byte(`{"2": {"model": "AX-12", "bus": "right"}}`), 0644)
+	assert.NoError(t, p.LoadDescription(fn))
+
+	// Servos which aren't described are on the first bus.
+	assert.Equal(t, left, p.Bus(1))
+	assert.Equal(t, right, p.Bus(2))
+	assert.Nil(t, NewPool().Bus(1))
+
+	p.Add(1, &servo.Servo{ID: 1})
+	p.Add(2, &servo.Servo{ID: 2})
+	p.Add(3, &servo.Servo{ID: 3})
+
+	st := p.BusStats()
+	assert.Equal(t, 2, st["left"].Servos)
+	assert.Equal(t, 1, st["right"].Servos)
+}
+
+func TestLock(t *testing.T) {
+	left := NewBus("left", network.New(&serial.FakeSerial{}))
+	right := NewBus("right", network.New(&serial.FakeSerial{}))
+	p := NewPool(left, right)
+
+	fn := filepath.Join(t.TempDir(), "robot.json")
+	os.WriteFile(fn, []byte(`{"2": {"model": "AX-12", "bus": "right"}}`), 0644)
+	assert.NoError(t, p.LoadDescription(fn))
+
+	rw := &fakeXL430{}
+	p.Add(1, &xl430{rw, rw, 1})
+	p.Add(2, &xl430{rw, rw, 2})
+
+	// Whoever is holding the right bus doesn't hold up the left one, but writes
+	// to the right wait until they're done.
+	right.Network.Lock()
+	assert.NoError(t, p.SetMovingSpeed(1, 10))
+
+	done := make(chan error)
+	go func() {
+		done <- p.SetMovingSpeed(2, 10)
+	}()
+
+	select {
+	case <-done:
+		t.Fatal("wrote to a locked bus")
+	case <-time.After(10 * time.Millisecond):
+	}
+
+	right.Network.Unlock()
+	assert.NoError(t, <-done)
+	assert.Equal(t, []string{"write 1 112 [10 0 0 0]", "write 2 112 [10 0 0 0]"}, rw.calls)
+}
+
+func TestAction(t *testing.T) {
+	l := &recordingSerial{}
+	r := &recordingSerial{}
+	p := NewPool(NewBus("left", network.New(l)), NewBus("right", network.New(r)))
+	p.SyncWrite = true
+
+	fn := filepath.Join(t.TempDir(), "robot.json")
+	os.WriteFile(fn, []byte(`{"2": {"model": "AX-12", "bus": "right"}}`), 0644)
+	assert.NoError(t, p.LoadDescription(fn))
+
+	p.RegMoveTo(1, 45)
+	p.RegMoveTo(2, 0)
+	p.RegMoveTo(3, 0)
+
+	// Each bus only gets the goals of its own servos.
+	assert.NoError(t, p.Action())
+	assert.Equal(t, []byte{
+		0xFF, 0xFF, 0xFE, 0x0A, 0x83, 0x1E, 0x02,
+		0x01, 0x9A, 0x02,
+		0x03, 0x00, 0x02,
+		0xB2,
+	}, l.written.Bytes()[:14])
+	assert.Equal(t, []byte{
+		0xFF, 0xFF, 0xFE, 0x07, 0x83, 0x1E, 0x02,
+		0x02, 0x00, 0x02,
+		0x53,
+	}, r.written.Bytes()[:11])
+
+	st := p.BusStats()
+	assert.Equal(t, 1, st["left"].Flushes)
+	assert.Equal(t, 1, st["left"].Packets)
+	assert.Equal(t, 14, st["left"].Bytes)
+	assert.Equal(t, 1, st["right"].Packets)
+	assert.Equal(t, 11, st["right"].Bytes)
+
+	// Nothing left to send, but the ACTIONs still go out.
+	assert.NoError(t, p.Action())
+	st = p.BusStats()
+	assert.Equal(t, 2, st["left"].Flushes)
+	assert.Equal(t, 1, st["left"].Packets)
+
+	// If a bus fails, the next goals of its servos fail too, until it recovers.
+	r.broken = true
+	p.RegMoveTo(2, 10)
+	assert.Error(t, p.Action())
+	assert.NoError(t, p.RegMoveTo(1, 0))
+	assert.Error(t, p.RegMoveTo(2, 0))
+
+	r.broken = false
+	assert.NoError(t, p.Action())
+	assert.NoError(t, p.RegMoveTo(2, 0))
+}
@@ -0,0 +1,143 @@
+package servos
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"strconv"
+)
+
+// Calibration corrects for the differences between an individual servo and the
+// ideal one assumed by the kinematics. The angles are all in degrees.
+type Calibration struct {
+
+	// The angle which the servo must be moved to for the joint to be at zero.
+	Offset float64 `json:"offset,omitempty"`
+
+	// If true, the servo turns the opposite way to the joint.
+	Reversed bool `json:"reversed,omitempty"`
+
+	// The amount of mechanical slack in the joint. The servo overshoots by half
+	// of this in the direction which the joint last moved, to take it up.
+	Backlash float64 `json:"backlash,omitempty"`
+}
+
+// LoadCalibration replaces the calibration of every servo with the contents of
+// the given JSON file, which is an object keyed by servo ID.
+func (p *Pool) LoadCalibration(filename string) error {
+	f, err := os.Open(filename)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	var raw map[string]Calibration
+	err = json.NewDecoder(f).Decode(&raw)
+	if err != nil {
+		return fmt.Errorf("%s (while parsing %s)", err, filename)
+	}
+
+	cals := make(map[int]Calibration, len(raw))
+	for k, c := range raw {
+		ID, err := strconv.Atoi(k)
+		if err != nil {
+			return fmt.Errorf("invalid servo ID: %q (in %s)", k, filename)
+		}
+		cals[ID] = c
+	}
+
+	p.calMu.Lock()
+	defer p.calMu.Unlock()
+	p.calibrations = cals
+
+	return nil
+}
+
+// SaveCalibration writes the calibration of every servo to the given file, in
+// the format read by LoadCalibration.
+func (p *Pool) SaveCalibration(filename string) error {
+	p.calMu.Lock()
+	raw := make(map[string]Calibration, len(p.calibrations))
+	for ID, c := range p.calibrations {
+		raw[strconv.Itoa(ID)] = c
+	}
+	p.calMu.Unlock()
+
+	b, err := json.MarshalIndent(raw, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(filename, append(b, '\n'), 0644)
+}
+
+// GetCalibration returns the calibration of the given servo.
+func (p *Pool) GetCalibration(ID int) Calibration {
+	p.calMu.Lock()
+	defer p.calMu.Unlock()
+	return p.calibrations[ID]
+}
+
+// SetCalibration replaces the calibration of the given servo.
+func (p *Pool) SetCalibration(ID int, c Calibration) {
+	p.calMu.Lock()
+	defer p.calMu.Unlock()
+	p.calibrations[ID] = c
+}
+
+// toServo returns the angle which the given servo should be moved to, for its
+// joint to be at the given angle. This updates the backlash compensation, so
+// should only be called once per write.
+func (p *Pool) toServo(ID int, angle float64) float64 {
+	p.calMu.Lock()
+	defer p.calMu.Unlock()
+
+	c := p.calibrations[ID]
+
+	// Only change direction if the joint actually moves, so the compensation
+	// holds while it's stationary.
+	if last, ok := p.lastAngle[ID]; ok && angle != last {
+		p.lastDir[ID] = math.Copysign(1, angle-last)
+	}
+	p.lastAngle[ID] = angle
+
+	// The overshoot is in the direction of the joint, not the servo, so must be
+	// applied before reversing.
+	return direction(c)*(angle+(p.lastDir[ID]*c.Backlash/2)) + c.Offset
+}
+
+// fromServo returns the angle of the joint, given the (present) angle of the
+// given servo. This is the inverse of toServo.
+func (p *Pool) fromServo(ID int, angle float64) float64 {
+	p.calMu.Lock()
+	defer p.calMu.Unlock()
+
+	c := p.calibrations[ID]
+	return (angle-c.Offset)*direction(c) - (p.lastDir[ID] * c.Backlash / 2)
+}
+
+func direction(c Calibration) float64 {
+	if c.Reversed {
+		return -1
+	}
+	return 1
+}
+
+// Angle returns the present angle of the joint driven by the given servo, with
+// the calibration removed.
+func (p *Pool) Angle(ID int) (float64, error) {
+	s, ok := p.Get(ID)
+	if !ok {
+		return 0, fmt.Errorf("no such servo: #%d", ID)
+	}
+
+	unlock := p.lock(ID)
+	a, err := s.Angle()
+	unlock()
+	if err != nil {
+		return 0, err
+	}
+
+	return p.fromServo(ID, a), nil
+}
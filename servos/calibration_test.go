@@ -0,0 +1,59 @@
+package servos
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCalibration(t *testing.T) {
+	p := NewPool()
+
+	// Uncalibrated servos are passed through.
+	assert.Equal(t, 30.0, p.toServo(1, 30))
+	assert.Equal(t, 30.0, p.fromServo(1, 30))
+
+	p.SetCalibration(2, Calibration{Offset: 5, Reversed: true, Backlash: 2})
+
+	// No backlash compensation until the joint has moved.
+	assert.Equal(t, -25.0, p.toServo(2, 30))
+	assert.Equal(t, 30.0, p.fromServo(2, -25))
+
+	// Moving the joint up overshoots it up, which is down for the servo, since
+	// it's reversed.
+	assert.Equal(t, -36.0, p.toServo(2, 40))
+	assert.Equal(t, 40.0, p.fromServo(2, -36))
+
+	// Holding still keeps the compensation.
+	assert.Equal(t, -36.0, p.toServo(2, 40))
+
+	// Moving down overshoots down.
+	assert.Equal(t, -14.0, p.toServo(2, 20))
+	assert.Equal(t, 20.0, p.fromServo(2, -14))
+
+	// Servos which aren't reversed overshoot the same way as the joint.
+	p.SetCalibration(3, Calibration{Offset: 5, Backlash: 2})
+	p.toServo(3, 30)
+	assert.Equal(t, 46.0, p.toServo(3, 40))
+	assert.Equal(t, 40.0, p.fromServo(3, 46))
+}
+
+func TestLoadCalibration(t *testing.T) {
+	p := NewPool()
+
+	fn := filepath.Join(t.TempDir(), "cal.json")
+	p.SetCalibration(14, Calibration{Offset: 5})
+	p.SetCalibration(22, Calibration{Offset: -2.5, Reversed: true, Backlash: 1})
+	assert.NoError(t, p.SaveCalibration(fn))
+
+	p = NewPool()
+	assert.NoError(t, p.LoadCalibration(fn))
+	assert.Equal(t, Calibration{Offset: 5}, p.GetCalibration(14))
+	assert.Equal(t, Calibration{Offset: -2.5, Reversed: true, Backlash: 1}, p.GetCalibration(22))
+	assert.Equal(t, Calibration{}, p.GetCalibration(99))
+
+	os.WriteFile(fn, []byte(`{"x": {"offset": 1}}`), 0644)
+	assert.Error(t, p.LoadCalibration(fn))
+}
@@ -0,0 +1,132 @@
+package servos
+
+import (
+	"math"
+	"sort"
+
+	"github.com/adammck/hexapod/actuator"
+)
+
+const (
+
+	// The AX-12 control table addresses of the compliance margins and slopes
+	// (CW then CCW), the moving speed and torque limit, and the punch.
+	addrComplianceMargin = 0x1A
+	addrMovingSpeed      = 0x20
+	addrPunch            = 0x30
+
+	// The ranges of the compliance registers.
+	marginMax = 255
+	slopeMin  = 2
+	slopeMax  = 128
+	punchMin  = 32
+)
+
+// registers are the AX-12 compliance registers of a single servo. Protocol 2
+// servos don't have these; they use PID gains instead.
+type registers struct {
+	margin      int
+	slope       int
+	punch       int
+	torqueLimit int
+	movingSpeed int
+}
+
+// toRegisters converts the given compliance to AX-12 units.
+func toRegisters(c actuator.Compliance) registers {
+	return registers{
+		margin:      clamp(0, marginMax, round(c.Margin*AX12.positionUnits)),
+		slope:       slope(c.Slope),
+		punch:       clamp(punchMin, AX12.torqueMax, round(c.Punch*float64(AX12.torqueMax))),
+		torqueLimit: AX12.torqueLimit(c.Torque),
+		movingSpeed: AX12.movingSpeed(c.Speed),
+	}
+}
+
+// slope returns the AX-12 compliance slope for the given angle. Only powers of
+// two are meaningful, so the nearest one is picked.
+func slope(angle float64) int {
+	u := angle * AX12.positionUnits
+	if u <= slopeMin {
+		return slopeMin
+	}
+
+	return clamp(slopeMin, slopeMax, 1<<uint(round(math.Log2(u))))
+}
+
+// setCompliance queues the given compliance of the given servo, to be sent by
+// the next Flush.
+func (p *Pool) setCompliance(ID int, r registers) {
+	p.syncMu.Lock()
+	defer p.syncMu.Unlock()
+	p.compliance[ID] = r
+	p.torqueLimits[ID] = r.torqueLimit
+}
+
+// setTorqueLimit writes the torque limit of the given servo, and remembers it,
+// to be restored after an alarm.
+func (p *Pool) setTorqueLimit(ID int, v int) error {
+	s, ok := p.Get(ID)
+	if !ok {
+		return nil
+	}
+
+	p.syncMu.Lock()
+	p.torqueLimits[ID] = v
+	p.syncMu.Unlock()
+
+	defer p.lock(ID)()
+	return s.SetTorqueLimit(v)
+}
+
+// torqueLimit returns the torque limit which the given servo was last set to.
+func (p *Pool) torqueLimit(ID int) int {
+	p.syncMu.Lock()
+	defer p.syncMu.Unlock()
+
+	if v, ok := p.torqueLimits[ID]; ok {
+		return v
+	}
+
+	return p.Model(ID).torqueMax
+}
+
+// compliancePackets returns the SYNC_WRITE packets to set the given compliance
+// registers (by servo ID), all in one go.
+func compliancePackets(rs map[int]registers) [][]byte {
+	ids := make([]int, 0, len(rs))
+	for ID := range rs {
+		ids = append(ids, ID)
+	}
+	sort.Ints(ids)
+
+	var margins, limits, punches [][]byte
+	for _, ID := range ids {
+		r := rs[ID]
+		margins = append(margins, []byte{byte(ID), byte(r.margin), byte(r.margin), byte(r.slope), byte(r.slope)})
+		limits = append(limits, []byte{byte(ID), low(r.movingSpeed), high(r.movingSpeed), low(r.torqueLimit), high(r.torqueLimit)})
+		punches = append(punches, []byte{byte(ID), low(r.punch), high(r.punch)})
+	}
+
+	var packets [][]byte
+	packets = append(packets, syncWritePackets(addrComplianceMargin, margins)...)
+	packets = append(packets, syncWritePackets(addrMovingSpeed, limits)...)
+	packets = append(packets, syncWritePackets(addrPunch, punches)...)
+
+	return packets
+}
+
+func round(v float64) int {
+	return int(math.Floor(v + 0.5))
+}
+
+func clamp(min, max, v int) int {
+	if v < min {
+		return min
+	}
+	if v > max {
+		return max
+	}
+
+	return v
+}
@@ -0,0 +1,58 @@
+package servos
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/adammck/dynamixel/servo"
+	"github.com/adammck/hexapod/actuator"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCompliancePackets(t *testing.T) {
+	assert.Equal(t, [][]byte{
+		{0xFF, 0xFF, 0xFE, 0x0E, 0x83, 0x1A, 0x04, 0x01, 0x04, 0x04, 0x80, 0x80, 0x02, 0x01, 0x01, 0x20, 0x20, 0x05},
+		{0xFF, 0xFF, 0xFE, 0x0E, 0x83, 0x20, 0x04, 0x01, 0x00, 0x00, 0x00, 0x01, 0x02, 0x00, 0x02, 0xFF, 0x03, 0x44},
+		{0xFF, 0xFF, 0xFE, 0x0A, 0x83, 0x30, 0x02, 0x01, 0x40, 0x00, 0x02, 0x20, 0x00, 0xDF},
+	}, compliancePackets(map[int]registers{
+		2: {margin: 1, slope: 32, punch: 32, torqueLimit: 1023, movingSpeed: 512},
+		1: {margin: 4, slope: 128, punch: 64, torqueLimit: 256, movingSpeed: 0},
+	}))
+}
+
+func TestToRegisters(t *testing.T) {
+	assert.Equal(t, registers{
+		margin:      1,
+		slope:       32,
+		punch:       32,
+		torqueLimit: 1023,
+		movingSpeed: 513,
+	}, toRegisters(actuator.Compliance{
+		Margin: 0.3,
+		Slope:  9.4,
+		Punch:  0,
+		Torque: 1,
+		Speed:  341,
+	}))
+
+	// Slopes snap to the nearest power of two.
+	assert.Equal(t, 2, slope(0))
+	assert.Equal(t, 64, slope(20))
+	assert.Equal(t, 128, slope(300))
+}
+
+func TestSetCompliance(t *testing.T) {
+	p := NewPool()
+	p.Add(1, &servo.Servo{ID: 1})
+
+	a, _ := p.Get(1)
+	j := &Joint{p, 1, AX12, a}
+	assert.NoError(t, j.SetCompliance(actuator.Compliance{Torque: 0.25}))
+	assert.Equal(t, 256, p.torqueLimit(1))
+
+	// Queued until the next flush, even without SyncWrite.
+	buf := &bytes.Buffer{}
+	assert.NoError(t, p.Flush(buf))
+	assert.Equal(t, 3, bytes.Count(buf.Bytes(), []byte{0xFF, 0xFF, 0xFE}))
+	assert.Empty(t, p.compliance)
+}
@@ -0,0 +1,88 @@
+package servos
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+)
+
+// Description declares the hardware of a single servo. Servos which aren't
+// described are assumed to be AX-12s, on the first bus.
+type Description struct {
+
+	// The name of the model, e.g. "AX-12" or "XL430".
+	Model string `json:"model"`
+
+	// The protocol version which the servo speaks. This is optional, since
+	// each model only speaks one here, but must match if given.
+	Protocol int `json:"protocol,omitempty"`
+
+	// The name of the bus which the servo is on. Optional; the default is the
+	// first one.
+	Bus string `json:"bus,omitempty"`
+}
+
+// LoadDescription reads the robot description from the given JSON file, which
+// is an object keyed by servo ID, like the calibration. It must be loaded before
+// any servos are added to the pool, and the buses must already exist.
+func (p *Pool) LoadDescription(filename string) error {
+	f, err := os.Open(filename)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	var raw map[string]Description
+	err = json.NewDecoder(f).Decode(&raw)
+	if err != nil {
+		return fmt.Errorf("%s (while parsing %s)", err, filename)
+	}
+
+	ms := make(map[int]*Model, len(raw))
+	bs := make(map[int]string, len(raw))
+	for k, d := range raw {
+		ID, err := strconv.Atoi(k)
+		if err != nil {
+			return fmt.Errorf("invalid servo ID: %q (in %s)", k, filename)
+		}
+
+		m, ok := models[d.Model]
+		if !ok {
+			return fmt.Errorf("unknown model of servo #%d: %q (in %s)", ID, d.Model, filename)
+		}
+
+		if d.Protocol != 0 && d.Protocol != m.Protocol {
+			return fmt.Errorf("%s servo #%d can't use protocol %d, only %d (in %s)", m.Name, ID, d.Protocol, m.Protocol, filename)
+		}
+
+		if d.Bus != "" {
+			if p.Buses.get(d.Bus) == nil {
+				return fmt.Errorf("unknown bus of servo #%d: %q (in %s)", ID, d.Bus, filename)
+			}
+
+			bs[ID] = d.Bus
+		}
+
+		ms[ID] = m
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.models = ms
+	p.busNames = bs
+
+	return nil
+}
+
+// Model returns the model of the given servo, according to the description.
+func (p *Pool) Model(ID int) *Model {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if m, ok := p.models[ID]; ok {
+		return m
+	}
+
+	return AX12
+}
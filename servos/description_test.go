@@ -0,0 +1,32 @@
+package servos
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoadDescription(t *testing.T) {
+	p := NewPool()
+	fn := filepath.Join(t.TempDir(), "robot.json")
+
+	os.WriteFile(fn, []byte(`{"14": {"model": "XL430", "protocol": 2}, "24": {"model": "AX-12"}}`), 0644)
+	assert.NoError(t, p.LoadDescription(fn))
+	assert.Equal(t, XL430, p.Model(14))
+	assert.Equal(t, AX12, p.Model(24))
+
+	// Servos which aren't described are AX-12s.
+	assert.Equal(t, AX12, p.Model(99))
+
+	os.WriteFile(fn, []byte(`{"14": {"model": "MX-28"}}`), 0644)
+	assert.Error(t, p.LoadDescription(fn))
+
+	os.WriteFile(fn, []byte(`{"14": {"model": "XL430", "protocol": 1}}`), 0644)
+	assert.Error(t, p.LoadDescription(fn))
+
+	// Servos can only be put on buses which exist.
+	os.WriteFile(fn, []byte(`{"14": {"model": "AX-12", "bus": "left"}}`), 0644)
+	assert.Error(t, p.LoadDescription(fn))
+}
@@ -0,0 +1,95 @@
+package servos
+
+import (
+	"github.com/adammck/hexapod/actuator"
+)
+
+// Joint is an Actuator driven by a servo in a pool, of any model.
+type Joint struct {
+	pool  *Pool
+	id    int
+	model *Model
+	servo Servo
+}
+
+// Actuator adds the servo with the given ID to the pool, and returns it as an
+// actuator. This is an actuator.Factory.
+func (p *Pool) Actuator(ID int) (actuator.Actuator, error) {
+	s, err := p.New(ID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Joint{p, ID, p.Model(ID), s}, nil
+}
+
+func (j *Joint) ID() int {
+	return j.id
+}
+
+// SetGoal buffers a move to the given angle. See RegMoveTo.
+func (j *Joint) SetGoal(angle float64) error {
+	return j.pool.RegMoveTo(j.id, angle)
+}
+
+// SetSpeed sets the moving speed. If SyncWrite is enabled, it's sent along with
+// the goal by the next Flush.
+func (j *Joint) SetSpeed(speed float64) error {
+	return j.pool.SetMovingSpeed(j.id, j.model.movingSpeed(speed))
+}
+
+func (j *Joint) SetTorque(torque float64) error {
+	return j.pool.setTorqueLimit(j.id, j.model.torqueLimit(torque))
+}
+
+// SetCompliance sets the compliance margins and slopes, punch, torque limit,
+// and moving speed. These are sent, along with those of every other servo, by
+// the next Flush. Only the AX-12 has compliance registers, so other models only
+// get the torque and speed.
+func (j *Joint) SetCompliance(c actuator.Compliance) error {
+	if j.model != AX12 {
+		err := j.SetTorque(c.Torque)
+		if err != nil {
+			return err
+		}
+
+		return j.SetSpeed(c.Speed)
+	}
+
+	j.pool.setCompliance(j.id, toRegisters(c))
+	return nil
+}
+
+func (j *Joint) Angle() (float64, error) {
+	return j.pool.Angle(j.id)
+}
+
+// Load reads the present load.
+func (j *Joint) Load() (float64, error) {
+	st, err := j.pool.ReadStatus(j.pool.Bus(j.id).Network, j.id)
+	if err != nil {
+		return 0, err
+	}
+
+	return float64(st.Load) / float64(AX12.loadMax), nil
+}
+
+func (j *Joint) Temperature() (float64, error) {
+	st, err := j.pool.ReadStatus(j.pool.Bus(j.id).Network, j.id)
+	if err != nil {
+		return 0, err
+	}
+
+	return float64(st.Temperature), nil
+}
+
+func (j *Joint) Voltage() (float64, error) {
+	defer j.pool.lock(j.id)()
+	return j.servo.Voltage()
+}
+
+// SetLED turns the LED on or off.
+func (j *Joint) SetLED(state bool) error {
+	defer j.pool.lock(j.id)()
+	return j.servo.SetLED(state)
+}
@@ -0,0 +1,96 @@
+package servos
+
+import (
+	"math"
+)
+
+// Model describes a kind of servo: which protocol it speaks, and how to convert
+// between its units and ours (degrees, degrees per second, and fractions of the
+// maximum torque).
+type Model struct {
+	Name string
+
+	// The Dynamixel protocol version which the servo speaks: 1 or 2.
+	Protocol int
+
+	// Positions are this many steps per degree, with zero at the center.
+	positionUnits  float64
+	positionCenter int
+	positionMax    int
+
+	// Moving speeds are this many degrees per second per unit. Zero means as
+	// fast as possible.
+	speedUnits float64
+	speedMax   int
+
+	// The maximum torque limit.
+	torqueMax int
+
+	// The maximum load, which Status.Load is scaled to.
+	loadMax int
+}
+
+var (
+
+	// AX12 is the Dynamixel AX-12A, which most of the joints are. Positions are
+	// 1024 steps over 300 degrees, and moving speeds about 0.111rpm per unit.
+	AX12 = &Model{
+		Name:           "AX-12",
+		Protocol:       1,
+		positionUnits:  1024.0 / 300.0,
+		positionCenter: 512,
+		positionMax:    1023,
+		speedUnits:     0.111 * 360 / 60,
+		speedMax:       1023,
+		torqueMax:      1023,
+		loadMax:        1023,
+	}
+
+	// XL430 is the Dynamixel XL430-W250, which has a full circle of 4096 steps,
+	// and profile velocities of 0.229rpm per unit. The torque limit is its goal
+	// PWM, and the load is in tenths of a percent.
+	XL430 = &Model{
+		Name:           "XL430",
+		Protocol:       2,
+		positionUnits:  4096.0 / 360.0,
+		positionCenter: 2048,
+		positionMax:    4095,
+		speedUnits:     0.229 * 360 / 60,
+		speedMax:       32767,
+		torqueMax:      885,
+		loadMax:        1000,
+	}
+
+	// The models which can be named in the robot description.
+	models = map[string]*Model{
+		AX12.Name:  AX12,
+		XL430.Name: XL430,
+	}
+)
+
+// position returns the goal position for the given servo angle (in degrees),
+// clamped to the range of the servo.
+func (m *Model) position(angle float64) int {
+	return clamp(0, m.positionMax, m.positionCenter+round(math.Remainder(angle, 360)*m.positionUnits))
+}
+
+// angle returns the servo angle (in degrees) of the given present position.
+// This is the inverse of position.
+func (m *Model) angle(position int) float64 {
+	return float64(position-m.positionCenter) / m.positionUnits
+}
+
+// movingSpeed returns the moving speed for the given speed (in degrees per
+// second), clamped to the range of the servo. Zero is as fast as possible.
+func (m *Model) movingSpeed(speed float64) int {
+	if speed == 0 {
+		return 0
+	}
+
+	return clamp(1, m.speedMax, int(math.Ceil(math.Abs(speed)/m.speedUnits)))
+}
+
+// torqueLimit returns the torque limit for the given fraction of the maximum.
+func (m *Model) torqueLimit(torque float64) int {
+	return clamp(0, m.torqueMax, round(torque*float64(m.torqueMax)))
+}
@@ -0,0 +1,28 @@
+package servos
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestModels(t *testing.T) {
+
+	// XL430s have a full circle of 4096 steps.
+	assert.Equal(t, 2048, XL430.position(0))
+	assert.Equal(t, 2048+512, XL430.position(45))
+	assert.Equal(t, 2048-1024, XL430.position(-90))
+	assert.Equal(t, 2048+1820, XL430.position(160))
+	assert.InDelta(t, 45.0, XL430.angle(2048+512), 0.001)
+	assert.InDelta(t, 45.0, AX12.angle(AX12.position(45)), 0.2)
+
+	// 60 degrees per second is 10rpm, which is 44 units of 0.229rpm.
+	assert.Equal(t, 44, XL430.movingSpeed(60))
+	assert.Equal(t, 0, XL430.movingSpeed(0))
+
+	// The torque limits are different, too.
+	assert.Equal(t, 1023, AX12.torqueLimit(1))
+	assert.Equal(t, 885, XL430.torqueLimit(1))
+	assert.Equal(t, 443, XL430.torqueLimit(0.5))
+	assert.Equal(t, 0, XL430.torqueLimit(-1))
+}
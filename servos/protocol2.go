@@ -0,0 +1,94 @@
+package servos
+
+// The dynamixel library's protocol 2 (see Bus) has the instructions needed to
+// drive the XL430s one at a time: PING, READ, WRITE, REG_WRITE, and ACTION. It
+// has no SYNC_WRITE or REBOOT, so this builds just those packets. Neither gets a
+// reply, so there are no status packets to parse here.
+
+const (
+
+	// The protocol 2 REBOOT instruction. SYNC_WRITE is the same as protocol 1.
+	instReboot = 0x08
+)
+
+// The first four bytes of every protocol 2 packet.
+var header2 = []byte{0xFF, 0xFF, 0xFD, 0x00}
+
+// packet2 returns a protocol 2 instruction packet for the given servo.
+func packet2(ID int, inst byte, params []byte) []byte {
+	params = stuff(params)
+	n := len(params) + 3
+
+	p := append([]byte{}, header2...)
+	p = append(p, byte(ID), low(n), high(n), inst)
+	p = append(p, params...)
+
+	crc := int(crc16(p))
+	return append(p, low(crc), high(crc))
+}
+
+// syncWritePackets2 returns the protocol 2 SYNC_WRITE packets to write the
+// given data, starting at the given address. Like syncWritePackets, each
+// element of data is the ID of a servo followed by the bytes to write to it,
+// and they must all be the same length.
+func syncWritePackets2(addr int, data [][]byte) [][]byte {
+	if len(data) == 0 {
+		return nil
+	}
+
+	// The header (4), ID, length (2), instruction, address (2), data length
+	// (2), and CRC (2). Stuffing might add a few more, but not many.
+	perPacket := (maxPacketLength - 14) / len(data[0])
+
+	var packets [][]byte
+	for len(data) > 0 {
+		n := len(data)
+		if n > perPacket {
+			n = perPacket
+		}
+
+		params := []byte{low(addr), high(addr), low(len(data[0]) - 1), high(len(data[0]) - 1)}
+		for _, d := range data[:n] {
+			params = append(params, d...)
+		}
+
+		packets = append(packets, packet2(broadcastID, instSyncWrite, params))
+		data = data[n:]
+	}
+
+	return packets
+}
+
+// stuff returns the given parameters with an extra 0xFD after any occurrence
+// of 0xFF 0xFF 0xFD, so they can't be mistaken for a header.
+func stuff(b []byte) []byte {
+	out := make([]byte, 0, len(b))
+
+	for i, v := range b {
+		out = append(out, v)
+		if i >= 2 && v == 0xFD && b[i-1] == 0xFF && b[i-2] == 0xFF {
+			out = append(out, 0xFD)
+		}
+	}
+
+	return out
+}
+
+// crc16 returns the protocol 2 CRC of the given bytes, which should be the
+// whole packet except the CRC itself.
+func crc16(b []byte) uint16 {
+	var crc uint16
+
+	for _, v := range b {
+		crc ^= uint16(v) << 8
+		for i := 0; i < 8; i++ {
+			if crc&0x8000 != 0 {
+				crc = (crc << 1) ^ 0x8005
+			} else {
+				crc <<= 1
+			}
+		}
+	}
+
+	return crc
+}
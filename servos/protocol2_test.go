@@ -0,0 +1,35 @@
+package servos
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPacket2(t *testing.T) {
+
+	// The examples from the protocol 2 manual: ping, read the present position
+	// of an XL430, and reboot.
+	assert.Equal(t, []byte{0xFF, 0xFF, 0xFD, 0x00, 0x01, 0x03, 0x00, 0x01, 0x19, 0x4E}, packet2(1, 0x01, nil))
+	assert.Equal(t, []byte{0xFF, 0xFF, 0xFD, 0x00, 0x01, 0x07, 0x00, 0x02, 0x84, 0x00, 0x04, 0x00, 0x1D, 0x15}, packet2(1, instReadData, []byte{0x84, 0x00, 0x04, 0x00}))
+	assert.Equal(t, []byte{0xFF, 0xFF, 0xFD, 0x00, 0x01, 0x03, 0x00, 0x08, 0x2F, 0x4E}, packet2(1, instReboot, nil))
+}
+
+func TestSyncWritePackets2(t *testing.T) {
+
+	// Also from the manual: goal positions of 150 and 170.
+	assert.Equal(t, [][]byte{{
+		0xFF, 0xFF, 0xFD, 0x00, 0xFE, 0x11, 0x00, 0x83, 0x74, 0x00, 0x04, 0x00,
+		0x01, 0x96, 0x00, 0x00, 0x00,
+		0x02, 0xAA, 0x00, 0x00, 0x00,
+		0x82, 0x87,
+	}}, syncWritePackets2(xlGoalPosition, [][]byte{
+		append([]byte{1}, le(150, 4)...),
+		append([]byte{2}, le(170, 4)...),
+	}))
+}
+
+func TestStuff(t *testing.T) {
+	assert.Equal(t, []byte{1, 2, 3}, stuff([]byte{1, 2, 3}))
+	assert.Equal(t, []byte{0xFF, 0xFF, 0xFD, 0xFD, 0x01}, stuff([]byte{0xFF, 0xFF, 0xFD, 0x01}))
+}
@@ -2,20 +2,117 @@ package servos
 
 import (
 	"fmt"
+	"sort"
+	"sync"
 
 	log "github.com/Sirupsen/logrus"
-	"github.com/adammck/dynamixel/network"
 	"github.com/adammck/dynamixel/servo"
 	"github.com/adammck/dynamixel/servo/ax"
 )
 
-type Pool []*servo.Servo
+// Servo is a single Dynamixel servo, of any model. The AX-12s are the dynamixel
+// library's own servo type; the XL430s are implemented here, on the library's
+// protocol 2. Angles are in degrees, but the rest is in the units of the model.
+type Servo interface {
+	Ping() error
 
-var servos Pool
+	// MoveTo buffers a move to the given angle, to be executed by the next
+	// ACTION of the protocol which the servo speaks.
+	MoveTo(angle float64) error
 
-// New adds a Servo (with sensible defaults) to the pool.
-func New(n *network.Network, ID int) (*servo.Servo, error) {
-	s, err := ax.New(n, ID)
+	Angle() (float64, error)
+	Voltage() (float64, error)
+	SetMovingSpeed(speed int) error
+	SetTorqueLimit(limit int) error
+	SetTorqueEnable(enable bool) error
+	SetLED(state bool) error
+}
+
+// Pool is the set of servos on one or more Dynamixel buses, along with
+// everything we know about them: their model and bus, their calibration, the
+// goals waiting to be sent, and their latest status. Each Hexapod has its own.
+type Pool struct {
+	// The Dynamixel networks which the servos are on.
+	Buses Buses
+
+	// SyncWrite enables batching of goals. If true, RegMoveTo and
+	// SetMovingSpeed collect the goal positions and moving speeds written
+	// during each tick, and Flush sends them all in a single SYNC_WRITE. If
+	// false, each goal is sent as a separate REG_WRITE, which is executed by
+	// the next ACTION.
+	SyncWrite bool
+
+	mu     sync.Mutex
+	servos map[int]Servo
+
+	// The model and bus of each servo, by ID, from the robot description.
+	// Servos which aren't in here are AX-12s, on the first bus.
+	models   map[int]*Model
+	busNames map[int]string
+
+	// The latest status of each servo, by ID. Updated by ReadStatus.
+	status map[int]Status
+
+	calMu sync.Mutex
+
+	// The calibration of each servo, by ID. Servos which aren't in here are
+	// assumed to be perfect.
+	calibrations map[int]Calibration
+
+	// The joint angle which each servo was last moved to, and the direction
+	// (+1 or -1) which it was moving in, for the backlash compensation.
+	lastAngle map[int]float64
+	lastDir   map[int]float64
+
+	syncMu     sync.Mutex
+	pending    map[int]*goal
+	compliance map[int]registers
+
+	// The torque limit which each servo was last set to, to restore it after
+	// an alarm.
+	torqueLimits map[int]int
+
+	alarmMu    sync.Mutex
+	recoveries map[int]*recovery
+}
+
+// NewPool returns an empty pool of servos on the given buses.
+func NewPool(buses ...*Bus) *Pool {
+	return &Pool{
+		Buses:        buses,
+		servos:       map[int]Servo{},
+		models:       map[int]*Model{},
+		busNames:     map[int]string{},
+		status:       map[int]Status{},
+		calibrations: map[int]Calibration{},
+		lastAngle:    map[int]float64{},
+		lastDir:      map[int]float64{},
+		pending:      map[int]*goal{},
+		compliance:   map[int]registers{},
+		torqueLimits: map[int]int{},
+		recoveries:   map[int]*recovery{},
+	}
+}
+
+// New adds a Servo (with sensible defaults) to the pool. The model (and so the
+// protocol) and bus are taken from the robot description.
+func (p *Pool) New(ID int) (Servo, error) {
+	b := p.Bus(ID)
+	if b == nil {
+		return nil, fmt.Errorf("no bus for servo #%d", ID)
+	}
+
+	if p.Model(ID).Protocol == 2 {
+		s, err := newXL430(b.protocol(2), b.Network, ID)
+		if err != nil {
+			return nil, err
+		}
+
+		p.Add(ID, s)
+		return s, nil
+	}
+
+	s, err := ax.New(b.Network, ID)
 	if err != nil {
 		return nil, err
 	}
@@ -29,7 +126,7 @@ func New(n *network.Network, ID int) (*servo.Servo, error) {
 
 	// Add to the pool as soon as we know the servo is available, to ensure that
 	// we power it down at shutdown even if the next lines fail.
-	servos = append(servos, s)
+	p.Add(ID, s)
 
 	err = s.Ping()
 	if err != nil {
@@ -44,11 +141,57 @@ func New(n *network.Network, ID int) (*servo.Servo, error) {
 	return s, nil
 }
 
+// Add adds an existing servo to the pool, replacing any with the same ID.
+func (p *Pool) Add(ID int, s Servo) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.servos[ID] = s
+}
+
+// Get returns the servo with the given ID, if it's in the pool.
+func (p *Pool) Get(ID int) (Servo, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	s, ok := p.servos[ID]
+	return s, ok
+}
+
+// IDs returns the IDs of all of the servos in the pool, in order.
+func (p *Pool) IDs() []int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	ids := make([]int, 0, len(p.servos))
+	for ID := range p.servos {
+		ids = append(ids, ID)
+	}
+
+	sort.Ints(ids)
+	return ids
+}
+
+// Servos returns all of the servos in the pool, ordered by ID.
+func (p *Pool) Servos() []Servo {
+	ids := p.IDs()
+	ss := make([]Servo, len(ids))
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for i, ID := range ids {
+		ss[i] = p.servos[ID]
+	}
+
+	return ss
+}
+
 // Shutdown powers off all servos in the pool. This should be called before
 // terminating the program, to ensure that servos don't stay powered up
 // indefinitely.
-func Shutdown() {
-	for _, s := range servos {
+func (p *Pool) Shutdown() {
+	for _, ID := range p.IDs() {
+		s, _ := p.Get(ID)
+		unlock := p.lock(ID)
+
 		err := s.SetMovingSpeed(0)
 		if err != nil {
 			log.Warnf("%s (while resetting moving speed)", err)
@@ -68,18 +211,46 @@ func Shutdown() {
 		if err != nil {
 			log.Warnf("%s (while disabling LED)", err)
 		}
+
+		unlock()
 	}
 }
 
+// RegMoveTo buffers a move of the joint driven by the given servo to the given
+// angle, applying its calibration. If SyncWrite is enabled, the move is sent by
+// the next Flush or Action, and an error is returned if the previous Action
+// failed to write to the servo's bus. Otherwise, it's executed by the next
+// ACTION.
+//
 // TODO: Call SetGoalPosition here, remove MoveTo from Dynamixel library.
-func RegMoveTo(s *servo.Servo, angle float64) error {
+func (p *Pool) RegMoveTo(ID int, angle float64) error {
+	if p.SyncWrite {
+		m := p.Model(ID)
+		pos := m.position(p.toServo(ID, angle))
+
+		p.syncMu.Lock()
+		p.queue(ID, m).position = pos
+		p.syncMu.Unlock()
+
+		if b := p.Bus(ID); b != nil {
+			return b.flushErr()
+		}
+
+		return nil
+	}
+
+	s, ok := p.Get(ID)
+	if !ok {
+		return fmt.Errorf("no such servo: #%d", ID)
+	}
 
 	// If the servo isn't in buffered mode, enable it for the duration of this
 	// method. This is a stupid hack.
-	if !s.Buffered {
+	if s, ok := s.(*servo.Servo); ok && !s.Buffered {
 		s.SetBuffered(true)
 		defer s.SetBuffered(false)
 	}
 
-	return s.MoveTo(angle)
+	defer p.lock(ID)()
+	return s.MoveTo(p.toServo(ID, angle))
 }
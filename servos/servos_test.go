@@ -0,0 +1,32 @@
+package servos
+
+import (
+	"testing"
+
+	"github.com/adammck/dynamixel/servo"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPool(t *testing.T) {
+	p := NewPool()
+	p.Add(20, &servo.Servo{ID: 20})
+	p.Add(10, &servo.Servo{ID: 10})
+
+	s, ok := p.Get(10)
+	assert.True(t, ok)
+	assert.Equal(t, 10, s.(*servo.Servo).ID)
+
+	_, ok = p.Get(30)
+	assert.False(t, ok)
+
+	assert.Equal(t, []int{10, 20}, p.IDs())
+	assert.Len(t, p.Servos(), 2)
+	assert.Equal(t, 20, p.Servos()[1].(*servo.Servo).ID)
+	assert.Empty(t, p.Status())
+
+	// Pools don't share anything.
+	q := NewPool()
+	q.SetCalibration(10, Calibration{Offset: 5})
+	assert.Empty(t, q.IDs())
+	assert.Equal(t, Calibration{}, p.GetCalibration(10))
+}
@@ -0,0 +1,151 @@
+package servos
+
+import (
+	"fmt"
+	"io"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+const (
+	instReadData = 0x02
+
+	// The AX-12 control table address of the present position. It's followed
+	// by the present speed, load, voltage, and temperature, so all of them can
+	// be read at once.
+	addrPresentPosition = 0x24
+	statusLength        = 8
+)
+
+// Status is a snapshot of the state of a single servo, as read from the bus.
+type Status struct {
+	ID int `json:"id"`
+
+	// The calibrated angle of the joint, in degrees.
+	Position float64 `json:"position"`
+
+	// The load on the servo, from -1023 to 1023 (scaled, for models which
+	// measure it differently). Positive is counter-clockwise.
+	Load int `json:"load"`
+
+	// The supply voltage, in volts.
+	Voltage float64 `json:"voltage"`
+
+	// The internal temperature, in degrees celsius.
+	Temperature int `json:"temperature"`
+
+	// The error bits from the status packet. Zero if all is well.
+	Error ErrorBits `json:"error"`
+
+	// When the status was read.
+	Time time.Time `json:"time"`
+}
+
+// ReadStatus reads the present position, load, voltage, temperature, and error
+// bits of the given servo in a single READ_DATA (or two, for a protocol 2
+// servo), and records it as the latest status of the servo. Protocol 1 servos
+// are read via the given network; protocol 2 servos via the library, on the bus
+// which they were added on. Either way, the servo's bus is locked meanwhile.
+func (p *Pool) ReadStatus(rw io.ReadWriter, ID int) (Status, error) {
+	var st Status
+	var err error
+
+	unlock := p.lock(ID)
+	if p.Model(ID).Protocol == 2 {
+		st, err = p.readStatus2(ID)
+	} else {
+		st, err = readStatus1(rw, ID)
+	}
+	unlock()
+
+	if b := p.Bus(ID); b != nil {
+		b.countRead(err)
+	}
+
+	if err != nil {
+		return Status{}, err
+	}
+
+	st.Position = p.fromServo(ID, st.Position)
+	st.Time = time.Now()
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if st.Error != 0 && p.status[ID].Error != st.Error {
+		log.Warnf("servo #%d reported error: %s", ID, st.Error)
+	}
+
+	p.status[ID] = st
+
+	return st, nil
+}
+
+// readStatus2 reads the status of the given protocol 2 servo, with the position
+// as the uncalibrated servo angle.
+func (p *Pool) readStatus2(ID int) (Status, error) {
+	s, _ := p.Get(ID)
+	x, ok := s.(*xl430)
+	if !ok {
+		return Status{}, fmt.Errorf("no such XL430: #%d", ID)
+	}
+
+	return x.readStatus()
+}
+
+// readStatus1 reads the status of the given protocol 1 servo, with the position
+// as the uncalibrated servo angle.
+func readStatus1(rw io.ReadWriter, ID int) (Status, error) {
+	req := []byte{0xFF, 0xFF, byte(ID), 4, instReadData, addrPresentPosition, statusLength}
+	_, err := rw.Write(append(req, checksum(req[2:])))
+	if err != nil {
+		return Status{}, fmt.Errorf("%s (while writing READ_DATA)", err)
+	}
+
+	// The header (2), ID, length, error, the data, and checksum.
+	b := make([]byte, statusLength+6)
+	_, err = io.ReadFull(rw, b)
+	if err != nil {
+		return Status{}, fmt.Errorf("%s (while reading status)", err)
+	}
+
+	if b[0] != 0xFF || b[1] != 0xFF || int(b[2]) != ID || int(b[3]) != statusLength+2 {
+		return Status{}, fmt.Errorf("invalid status packet: %v", b)
+	}
+
+	if checksum(b[2:len(b)-1]) != b[len(b)-1] {
+		return Status{}, fmt.Errorf("bad checksum in status packet: %v", b)
+	}
+
+	d := b[5:]
+	pos := int(d[0]) | int(d[1])<<8
+	load := int(d[4]) | int(d[5])<<8
+
+	// The magnitude is in the low ten bits, and the direction in the next one.
+	if load&0x400 != 0 {
+		load = -(load & 0x3FF)
+	}
+
+	return Status{
+		ID:          ID,
+		Position:    AX12.angle(pos),
+		Load:        load,
+		Voltage:     float64(d[6]) / 10,
+		Temperature: int(d[7]),
+		Error:       ErrorBits(b[4]),
+	}, nil
+}
+
+// Status returns the latest status of each servo which has been read, by ID.
+func (p *Pool) Status() map[int]Status {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	m := make(map[int]Status, len(p.status))
+	for ID, st := range p.status {
+		m[ID] = st
+	}
+
+	return m
+}
@@ -0,0 +1,212 @@
+package servos
+
+import (
+	"fmt"
+	"io"
+	"sort"
+)
+
+const (
+
+	// Protocol 1 packet fields.
+	broadcastID   = 0xFE
+	instSyncWrite = 0x83
+
+	// The AX-12 control table address of the goal position. The moving speed
+	// is right after it, so both can be written in the same packet.
+	addrGoalPosition = 0x1E
+
+	// The length byte of a packet can't exceed 255, which limits the number of
+	// servos which can be written to by a single SYNC_WRITE. Protocol 2 packets
+	// can be longer, but we keep them short too.
+	maxPacketLength = 255
+)
+
+// goal is a goal position and moving speed, in the units of the servo's model.
+type goal struct {
+	ID       int
+	model    *Model
+	position int
+
+	// The moving speed, or -1 to leave it as it is.
+	speed int
+}
+
+// queue returns the pending goal of the given servo, creating it if needed.
+// The caller must hold syncMu.
+func (p *Pool) queue(ID int, m *Model) *goal {
+	g, ok := p.pending[ID]
+	if !ok {
+		g = &goal{ID: ID, model: m, position: -1, speed: -1}
+		p.pending[ID] = g
+	}
+
+	return g
+}
+
+// SetMovingSpeed sets the moving speed of the given servo, in the units of its
+// model. If SyncWrite is enabled, it's sent along with the goal position by the
+// next Flush.
+func (p *Pool) SetMovingSpeed(ID int, speed int) error {
+	if !p.SyncWrite {
+		s, ok := p.Get(ID)
+		if !ok {
+			return fmt.Errorf("no such servo: #%d", ID)
+		}
+
+		defer p.lock(ID)()
+		return s.SetMovingSpeed(speed)
+	}
+
+	m := p.Model(ID)
+
+	p.syncMu.Lock()
+	defer p.syncMu.Unlock()
+	p.queue(ID, m).speed = speed
+
+	return nil
+}
+
+// Flush writes any compliance settings, then all of the goals, collected since
+// the previous Flush to the given network, in as few SYNC_WRITE packets as
+// possible. Usually that's one per protocol, but goals without moving speeds
+// must be sent separately from those with them. Goals are only collected if
+// SyncWrite is enabled, but compliance settings always are. This ignores the
+// buses, so is only useful if there's just one; see Action.
+func (p *Pool) Flush(w io.Writer) error {
+	packets := p.packets(func(int) bool {
+		return true
+	})
+
+	for _, p := range packets {
+		_, err := w.Write(p)
+		if err != nil {
+			return fmt.Errorf("%s (while writing SYNC_WRITE)", err)
+		}
+	}
+
+	return nil
+}
+
+// packets removes the pending compliance settings and goals of the servos for
+// which the given function returns true, and returns the SYNC_WRITE packets to
+// send them.
+func (p *Pool) packets(include func(ID int) bool) [][]byte {
+	p.syncMu.Lock()
+	var goals []*goal
+	for ID, g := range p.pending {
+		if include(ID) {
+			goals = append(goals, g)
+			delete(p.pending, ID)
+		}
+	}
+	rs := map[int]registers{}
+	for ID, r := range p.compliance {
+		if include(ID) {
+			rs[ID] = r
+			delete(p.compliance, ID)
+		}
+	}
+	p.syncMu.Unlock()
+
+	packets := compliancePackets(rs)
+
+	sort.Slice(goals, func(i, j int) bool {
+		return goals[i].ID < goals[j].ID
+	})
+
+	var withSpeed, withoutSpeed, speedOnly [][]byte
+	var withSpeed2, withoutSpeed2, speedOnly2 [][]byte
+	for _, g := range goals {
+		if g.model.Protocol == 2 {
+			ID := []byte{byte(g.ID)}
+
+			// Here, the profile velocity comes before the goal position.
+			switch {
+			case g.position < 0:
+				speedOnly2 = append(speedOnly2, append(ID, le(g.speed, 4)...))
+
+			case g.speed >= 0:
+				withSpeed2 = append(withSpeed2, append(append(ID, le(g.speed, 4)...), le(g.position, 4)...))
+
+			default:
+				withoutSpeed2 = append(withoutSpeed2, append(ID, le(g.position, 4)...))
+			}
+
+			continue
+		}
+
+		switch {
+
+		// A speed without a goal position can't go in the same packet, since
+		// the position comes first.
+		case g.position < 0:
+			speedOnly = append(speedOnly, []byte{byte(g.ID), low(g.speed), high(g.speed)})
+
+		case g.speed >= 0:
+			withSpeed = append(withSpeed, []byte{byte(g.ID), low(g.position), high(g.position), low(g.speed), high(g.speed)})
+
+		default:
+			withoutSpeed = append(withoutSpeed, []byte{byte(g.ID), low(g.position), high(g.position)})
+		}
+	}
+
+	packets = append(packets, syncWritePackets(addrMovingSpeed, speedOnly)...)
+	packets = append(packets, syncWritePackets(addrGoalPosition, withoutSpeed)...)
+	packets = append(packets, syncWritePackets(addrGoalPosition, withSpeed)...)
+	packets = append(packets, syncWritePackets2(xlProfileVelocity, speedOnly2)...)
+	packets = append(packets, syncWritePackets2(xlGoalPosition, withoutSpeed2)...)
+	packets = append(packets, syncWritePackets2(xlProfileVelocity, withSpeed2)...)
+
+	return packets
+}
+
+// syncWritePackets returns the SYNC_WRITE packets to write the given data,
+// starting at the given address. Each element of data is the ID of a servo,
+// followed by the bytes to write to it, and must all be the same length.
+func syncWritePackets(addr byte, data [][]byte) [][]byte {
+	if len(data) == 0 {
+		return nil
+	}
+
+	// The header (2), ID, length, instruction, address, data length, and
+	// checksum, but the length byte doesn't count the first four.
+	perPacket := (maxPacketLength - 4) / len(data[0])
+
+	var packets [][]byte
+	for len(data) > 0 {
+		n := len(data)
+		if n > perPacket {
+			n = perPacket
+		}
+
+		p := []byte{0xFF, 0xFF, broadcastID, byte((len(data[0]) * n) + 4), instSyncWrite, addr, byte(len(data[0]) - 1)}
+		for _, d := range data[:n] {
+			p = append(p, d...)
+		}
+
+		packets = append(packets, append(p, checksum(p[2:])))
+		data = data[n:]
+	}
+
+	return packets
+}
+
+// checksum returns the protocol 1 checksum of the given bytes, which should be
+// the whole packet except the header.
+func checksum(b []byte) byte {
+	var sum byte
+	for _, v := range b {
+		sum += v
+	}
+
+	return ^sum
+}
+
+func low(v int) byte {
+	return byte(v & 0xFF)
+}
+
+func high(v int) byte {
+	return byte((v >> 8) & 0xFF)
+}
@@ -0,0 +1,181 @@
+package servos
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/adammck/dynamixel/network"
+	"github.com/adammck/dynamixel/servo"
+	"github.com/adammck/dynamixel/servo/ax"
+	"github.com/adammck/hexapod/fake/serial"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSyncWritePackets(t *testing.T) {
+
+	// The example from the AX-12 manual: goal position and moving speed of four
+	// servos, in a single packet.
+	p := syncWritePackets(addrGoalPosition, [][]byte{
+		{0x00, 0x10, 0x00, 0x50, 0x01},
+		{0x01, 0x20, 0x02, 0x60, 0x03},
+		{0x02, 0x30, 0x00, 0x70, 0x01},
+		{0x03, 0x20, 0x02, 0x80, 0x03},
+	})
+
+	assert.Equal(t, [][]byte{{
+		0xFF, 0xFF, 0xFE, 0x18, 0x83, 0x1E, 0x04,
+		0x00, 0x10, 0x00, 0x50, 0x01,
+		0x01, 0x20, 0x02, 0x60, 0x03,
+		0x02, 0x30, 0x00, 0x70, 0x01,
+		0x03, 0x20, 0x02, 0x80, 0x03,
+		0x12,
+	}}, p)
+
+	// Too many servos for one packet are split up.
+	data := make([][]byte, 100)
+	for i := range data {
+		data[i] = []byte{byte(i), 0, 0}
+	}
+
+	p = syncWritePackets(addrGoalPosition, data)
+	assert.Len(t, p, 2)
+	assert.Equal(t, byte((83*3)+4), p[0][3])
+	assert.Equal(t, byte(((100-83)*3)+4), p[1][3])
+	assert.Equal(t, byte(83), p[1][7])
+}
+
+func TestPosition(t *testing.T) {
+	assert.Equal(t, 512, AX12.position(0))
+	assert.Equal(t, 1023, AX12.position(150))
+	assert.Equal(t, 0, AX12.position(-150))
+	assert.Equal(t, 512+154, AX12.position(45))
+	assert.Equal(t, 512-154, AX12.position(315))
+	assert.Equal(t, 1023, AX12.position(170))
+}
+
+func TestMovingSpeed(t *testing.T) {
+
+	// 60 degrees per second is 10rpm.
+	assert.Equal(t, 91, AX12.movingSpeed(60))
+	assert.Equal(t, 91, AX12.movingSpeed(-60))
+
+	// Zero is as fast as possible.
+	assert.Equal(t, 0, AX12.movingSpeed(0))
+	assert.Equal(t, 1, AX12.movingSpeed(0.1))
+	assert.Equal(t, 1023, AX12.movingSpeed(18000))
+}
+
+func TestFlush(t *testing.T) {
+	p := NewPool()
+	p.SyncWrite = true
+
+	p.RegMoveTo(2, 0)
+	p.RegMoveTo(1, 45)
+	p.RegMoveTo(1, -45)
+
+	buf := &bytes.Buffer{}
+	err := p.Flush(buf)
+	assert.NoError(t, err)
+	assert.Equal(t, []byte{
+		0xFF, 0xFF, 0xFE, 0x0A, 0x83, 0x1E, 0x02,
+		0x01, 0x66, 0x01,
+		0x02, 0x00, 0x02,
+		0xE8,
+	}, buf.Bytes())
+
+	// Nothing left to send.
+	buf.Reset()
+	assert.NoError(t, p.Flush(buf))
+	assert.Empty(t, buf.Bytes())
+
+	// Goals with speeds are sent separately.
+	p.RegMoveTo(2, 0)
+	p.SetMovingSpeed(2, 100)
+	p.RegMoveTo(1, 0)
+	assert.NoError(t, p.Flush(buf))
+	assert.Equal(t, 2, bytes.Count(buf.Bytes(), []byte{0xFF, 0xFF, 0xFE}))
+
+	// Speeds without goals are sent on their own.
+	buf.Reset()
+	p.SetMovingSpeed(2, 100)
+	assert.NoError(t, p.Flush(buf))
+	assert.Equal(t, []byte{0xFF, 0xFF, 0xFE, 0x07, 0x83, 0x20, 0x02, 0x02, 0x64, 0x00, 0xEF}, buf.Bytes())
+}
+
+// countingSerial is a fake serial port which counts the bytes written to it.
+type countingSerial struct {
+	serial.FakeSerial
+	n int
+}
+
+func (s *countingSerial) Write(p []byte) (int, error) {
+	s.n += len(p)
+	return s.FakeSerial.Write(p)
+}
+
+// fakeServo returns a servo on the given network, which doesn't send ACKs, as
+// New would. Unlike New, it isn't added to the pool.
+func fakeServo(tb testing.TB, n *network.Network, ID int) *servo.Servo {
+	s, err := ax.New(n, ID)
+	if err != nil {
+		tb.Fatal(err)
+	}
+
+	err = s.SetReturnLevel(1)
+	if err != nil {
+		tb.Fatal(err)
+	}
+
+	return s
+}
+
+// benchmarkTick writes a goal to each of the 26 servos, as the legs and head
+// do each tick, then sends them with Flush and ACTION.
+func benchmarkTick(b *testing.B, batch bool) {
+	s := &countingSerial{}
+	n := network.New(s)
+
+	p := NewPool(NewBus("test", n))
+	p.SyncWrite = batch
+
+	for ID := 1; ID <= 26; ID++ {
+		p.Add(ID, fakeServo(b, n, ID))
+	}
+
+	s.Reset()
+	s.n = 0
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for _, ID := range p.IDs() {
+			err := p.RegMoveTo(ID, float64((i+ID)%90))
+			if err != nil {
+				b.Fatal(err)
+			}
+		}
+
+		err := p.Flush(n)
+		if err != nil {
+			b.Fatal(err)
+		}
+
+		// The ACTION is broadcast either way, but does nothing after a
+		// SYNC_WRITE. Count it anyway, to be fair.
+		_, err = n.Write([]byte{0xFF, 0xFF, 0xFE, 0x02, 0x05, 0xFA})
+		if err != nil {
+			b.Fatal(err)
+		}
+
+		s.Reset()
+	}
+
+	b.ReportMetric(float64(s.n)/float64(b.N), "bytes/tick")
+}
+
+func BenchmarkRegWrite(b *testing.B) {
+	benchmarkTick(b, false)
+}
+
+func BenchmarkSyncWrite(b *testing.B) {
+	benchmarkTick(b, true)
+}
@@ -0,0 +1,240 @@
+package servos
+
+import (
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/adammck/dynamixel/iface"
+)
+
+const (
+
+	// The XL430 control table addresses which we use, and their sizes. Unlike
+	// the AX-12, the profile velocity comes before the goal position.
+	xlReturnDelayTime   = 9
+	xlTorqueEnable      = 64
+	xlLED               = 65
+	xlStatusReturnLevel = 68
+	xlHardwareError     = 70
+	xlGoalPWM           = 100
+	xlProfileVelocity   = 112
+	xlGoalPosition      = 116
+	xlPresentLoad       = 126
+	xlPresentPosition   = 132
+	xlPresentVoltage    = 144
+
+	// The present load is followed by the velocity, position, trajectories,
+	// voltage, and temperature, so all of them can be read at once.
+	xlStatusLength = 21
+
+	// The hardware error bits which have no AX-12 equivalent: the encoder and
+	// electrical shock errors.
+	xlEncoderError = 1 << 3
+	xlShockError   = 1 << 4
+
+	// How long to wait for the servo to come back after a REBOOT.
+	xlRebootTime = 100 * time.Millisecond
+)
+
+// xl430 is an XL430 servo, which speaks protocol 2. The dynamixel library has
+// no servo type for it (only the AX-12), so this wraps the library's protocol 2
+// with just enough of the control table to drive a joint. The network is only
+// used directly for REBOOT, which the protocol doesn't have.
+type xl430 struct {
+	p  iface.Protocol
+	w  io.Writer
+	ID int
+}
+
+// newXL430 returns the XL430 with the given ID, on the given (protocol 2) bus,
+// configured like New configures the AX-12s: no status packets except for reads
+// and pings, with no delay, and ready to move.
+func newXL430(p iface.Protocol, w io.Writer, ID int) (*xl430, error) {
+	s := &xl430{p, w, ID}
+
+	err := s.setReturnLevel()
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.write(xlReturnDelayTime, 1, 0)
+	if err != nil {
+		return nil, fmt.Errorf("%s (while setting return delay)", err)
+	}
+
+	// AX-12s enable their torque as soon as they're given a goal, but XL430s
+	// ignore goals until it's enabled. The return delay is in the EEPROM, which
+	// can only be written with the torque off, so this must come after it.
+	err = s.SetTorqueEnable(true)
+	if err != nil {
+		return nil, fmt.Errorf("%s (while enabling torque)", err)
+	}
+
+	return s, nil
+}
+
+// setReturnLevel stops the servo from sending status packets for writes, and
+// checks that it's there. This is in RAM, so must be done again after a reboot.
+func (s *xl430) setReturnLevel() error {
+
+	// The servo might already be configured like this, so we can't wait for the
+	// reply.
+	err := s.write(xlStatusReturnLevel, 1, 1)
+	if err != nil {
+		return fmt.Errorf("%s (while setting return level)", err)
+	}
+
+	err = s.Ping()
+	if err != nil {
+		return fmt.Errorf("%s (while pinging)", err)
+	}
+
+	return nil
+}
+
+// write writes the given value to the given register. This doesn't wait for a
+// reply, since the servo doesn't send one.
+func (s *xl430) write(addr, size, v int) error {
+	return s.p.WriteData(s.ID, addr, le(v, size), false)
+}
+
+// read reads the given number of bytes starting at the given register.
+func (s *xl430) read(addr, size int) ([]byte, error) {
+	b, err := s.p.ReadData(s.ID, addr, size)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(b) != size {
+		return nil, fmt.Errorf("expected %d bytes from servo #%d, got %d", size, s.ID, len(b))
+	}
+
+	return b, nil
+}
+
+func (s *xl430) Ping() error {
+	return s.p.Ping(s.ID)
+}
+
+// Reboot restarts the servo, which is the only way to clear its hardware error,
+// and waits for it to come back. Like a power cycle, this resets the RAM half
+// of the control table, so the torque is left disabled.
+func (s *xl430) Reboot() error {
+	_, err := s.w.Write(packet2(s.ID, instReboot, nil))
+	if err != nil {
+		return err
+	}
+
+	time.Sleep(xlRebootTime)
+
+	return s.setReturnLevel()
+}
+
+// MoveTo buffers a move to the given angle, with REG_WRITE, to be executed by
+// the next (protocol 2) ACTION. The pool only ever moves servos like this.
+func (s *xl430) MoveTo(angle float64) error {
+	return s.p.RegWrite(s.ID, xlGoalPosition, le(XL430.position(angle), 4), false)
+}
+
+func (s *xl430) Angle() (float64, error) {
+	b, err := s.read(xlPresentPosition, 4)
+	if err != nil {
+		return 0, err
+	}
+
+	return XL430.angle(int(int32(fromLE(b)))), nil
+}
+
+func (s *xl430) Voltage() (float64, error) {
+	b, err := s.read(xlPresentVoltage, 2)
+	if err != nil {
+		return 0, err
+	}
+
+	return float64(fromLE(b)) / 10, nil
+}
+
+// SetMovingSpeed sets the profile velocity, which is the XL430 equivalent of
+// the AX-12 moving speed.
+func (s *xl430) SetMovingSpeed(speed int) error {
+	return s.write(xlProfileVelocity, 4, speed)
+}
+
+// SetTorqueLimit sets the goal PWM, which limits the torque like the AX-12
+// torque limit does.
+func (s *xl430) SetTorqueLimit(limit int) error {
+	return s.write(xlGoalPWM, 2, limit)
+}
+
+func (s *xl430) SetTorqueEnable(enable bool) error {
+	return s.write(xlTorqueEnable, 1, boolToInt(enable))
+}
+
+func (s *xl430) SetLED(state bool) error {
+	return s.write(xlLED, 1, boolToInt(state))
+}
+
+// readStatus reads the present position (as the uncalibrated servo angle),
+// load, voltage, and temperature of the servo, and its hardware error bits.
+func (s *xl430) readStatus() (Status, error) {
+	b, err := s.read(xlPresentLoad, xlStatusLength)
+	if err != nil {
+		return Status{}, err
+	}
+
+	// The load is signed, in tenths of a percent. Scale it to the AX-12 range,
+	// which the thresholds are in.
+	st := Status{
+		ID:          s.ID,
+		Position:    XL430.angle(int(int32(fromLE(b[6:10])))),
+		Load:        round(float64(int16(fromLE(b[0:2]))) * float64(AX12.loadMax) / float64(XL430.loadMax)),
+		Voltage:     float64(fromLE(b[18:20])) / 10,
+		Temperature: int(b[20]),
+	}
+
+	// The hardware error is far from the rest, so must be read separately. The
+	// alert bit of the status packet would say whether there is one, but the
+	// library doesn't return it.
+	h, err := s.read(xlHardwareError, 1)
+	if err != nil {
+		return Status{}, fmt.Errorf("%s (while reading hardware error)", err)
+	}
+
+	// The input voltage, overheating, and overload bits are the same as the
+	// AX-12. The others have no equivalent, so are reported as overloads,
+	// which at least trips the alarm.
+	st.Error = ErrorBits(h[0]) & (InputVoltageError | OverheatingError | OverloadError)
+	if h[0]&(xlEncoderError|xlShockError) != 0 {
+		st.Error |= OverloadError
+	}
+
+	return st, nil
+}
+
+// le returns the given value as the given number of bytes, little-endian.
+func le(v, size int) []byte {
+	b := make([]byte, size)
+	for i := range b {
+		b[i] = byte(v >> (8 * uint(i)))
+	}
+
+	return b
+}
+
+// fromLE is the inverse of le.
+func fromLE(b []byte) uint32 {
+	var v uint32
+	for i, x := range b {
+		v |= uint32(x) << (8 * uint(i))
+	}
+
+	return v
+}
+
+func boolToInt(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,130 @@
+package servos
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeXL430 is the protocol 2 bus of a single XL430. It answers every READ with
+// the given bytes of its control table, and records the other instructions,
+// including the REBOOTs which are written straight to the network.
+type fakeXL430 struct {
+	table [150]byte
+	calls []string
+}
+
+func (s *fakeXL430) Ping(ident int) error {
+	s.calls = append(s.calls, fmt.Sprintf("ping %d", ident))
+	return nil
+}
+
+func (s *fakeXL430) ReadData(ident, addr, n int) ([]byte, error) {
+	return append([]byte{}, s.table[addr:addr+n]...), nil
+}
+
+func (s *fakeXL430) WriteData(ident, addr int, params []byte, expectStatusPacket bool) error {
+	s.calls = append(s.calls, fmt.Sprintf("write %d %d %v", ident, addr, params))
+	return nil
+}
+
+func (s *fakeXL430) RegWrite(ident, addr int, params []byte, expectStatusPacket bool) error {
+	s.calls = append(s.calls, fmt.Sprintf("regwrite %d %d %v", ident, addr, params))
+	return nil
+}
+
+func (s *fakeXL430) Action() error {
+	s.calls = append(s.calls, "action")
+	return nil
+}
+
+func (s *fakeXL430) Write(p []byte) (int, error) {
+	s.calls = append(s.calls, fmt.Sprintf("packet %v", p))
+	return len(p), nil
+}
+
+func TestXL430Status(t *testing.T) {
+	rw := &fakeXL430{}
+	copy(rw.table[xlPresentLoad:], le(-1000, 2))
+	copy(rw.table[xlPresentPosition:], le(2048+512, 4))
+	copy(rw.table[xlPresentVoltage:], le(111, 2))
+	rw.table[xlPresentVoltage+2] = 40
+
+	p := NewPool()
+	p.models[3] = XL430
+	p.Add(3, &xl430{rw, rw, 3})
+	p.SetCalibration(3, Calibration{Offset: 5})
+
+	st, err := p.ReadStatus(&bytes.Buffer{}, 3)
+	assert.NoError(t, err)
+	assert.Equal(t, 3, st.ID)
+	assert.InDelta(t, 40.0, st.Position, 0.001)
+	assert.Equal(t, -1023, st.Load)
+	assert.Equal(t, 11.1, st.Voltage)
+	assert.Equal(t, 40, st.Temperature)
+	assert.Equal(t, ErrorBits(0), st.Error)
+
+	// Hardware errors are read separately, and the encoder error (which the
+	// AX-12 doesn't have) is an overload.
+	rw.table[xlHardwareError] = 1<<2 | xlEncoderError
+	st, err = p.ReadStatus(&bytes.Buffer{}, 3)
+	assert.NoError(t, err)
+	assert.Equal(t, OverheatingError|OverloadError, st.Error)
+}
+
+func TestXL430(t *testing.T) {
+	rw := &fakeXL430{}
+	s := &xl430{rw, rw, 5}
+
+	assert.NoError(t, s.MoveTo(45))
+	assert.NoError(t, s.SetTorqueLimit(885))
+	assert.Equal(t, []string{
+		"regwrite 5 116 [0 10 0 0]",
+		"write 5 100 [117 3]",
+	}, rw.calls)
+}
+
+func TestXL430Recover(t *testing.T) {
+	rw := &fakeXL430{}
+
+	p := NewPool()
+	p.SyncWrite = true
+	p.models[3] = XL430
+	p.Add(3, &xl430{rw, rw, 3})
+	p.RegMoveTo(3, 45)
+	p.Flush(&bytes.Buffer{})
+
+	// The servo is rebooted to clear the hardware error, then set up again,
+	// before the torque is restored and the goal re-sent.
+	now := time.Unix(1, 0)
+	assert.NoError(t, p.Recover(Status{ID: 3, Error: OverloadError, Time: now}, now))
+	assert.Equal(t, []string{
+		fmt.Sprintf("packet %v", packet2(3, instReboot, nil)),
+		"write 3 68 [1]",
+		"ping 3",
+		fmt.Sprintf("write 3 100 %v", le(p.torqueLimit(3), 2)),
+		"write 3 64 [1]",
+	}, rw.calls)
+	assert.Equal(t, XL430.position(45), p.pending[3].position)
+}
+
+func TestFlushMixed(t *testing.T) {
+	p := NewPool()
+	p.SyncWrite = true
+	p.models[3] = XL430
+
+	p.RegMoveTo(1, 0)
+	p.RegMoveTo(3, 45)
+	p.SetMovingSpeed(3, 44)
+
+	// One packet for each protocol.
+	buf := &bytes.Buffer{}
+	assert.NoError(t, p.Flush(buf))
+	assert.Equal(t, bytes.Join([][]byte{
+		syncWritePackets(addrGoalPosition, [][]byte{{1, 0x00, 0x02}})[0],
+		syncWritePackets2(xlProfileVelocity, [][]byte{{3, 44, 0, 0, 0, 0x00, 0x0A, 0x00, 0x00}})[0],
+	}, nil), buf.Bytes())
+}
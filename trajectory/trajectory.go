@@ -0,0 +1,117 @@
+package trajectory
+
+import (
+	"math"
+
+	"github.com/adammck/hexapod/math3d"
+)
+
+// Limits are the maximum velocity, acceleration, and jerk of a trajectory, in
+// units (e.g. mm or degrees) per second, per second squared, and per second
+// cubed. Zero means unlimited.
+type Limits struct {
+	Velocity     float64
+	Acceleration float64
+	Jerk         float64
+}
+
+// Trajectory moves smoothly towards a target which might jump around, without
+// exceeding its limits. It works in up to three dimensions; scalars can just
+// use the X axis.
+type Trajectory struct {
+	Limits Limits
+
+	pos math3d.Vector3
+	vel math3d.Vector3
+	acc math3d.Vector3
+}
+
+// New returns a trajectory at rest at the origin.
+func New(l Limits) Trajectory {
+	return Trajectory{Limits: l}
+}
+
+// Reset moves the trajectory to the given position, at rest.
+func (t *Trajectory) Reset(pos math3d.Vector3) {
+	t.pos = pos
+	t.vel = math3d.ZeroVector3
+	t.acc = math3d.ZeroVector3
+}
+
+// Position returns the current position.
+func (t *Trajectory) Position() math3d.Vector3 {
+	return t.pos
+}
+
+// Velocity returns the current velocity, in units per second.
+func (t *Trajectory) Velocity() math3d.Vector3 {
+	return t.vel
+}
+
+// Update advances the trajectory by dt seconds towards the given target, and
+// returns the new position. It heads straight for the target as fast as the
+// limits allow, slowing down in time to stop there.
+func (t *Trajectory) Update(target math3d.Vector3, dt float64) math3d.Vector3 {
+	if dt <= 0 {
+		return t.pos
+	}
+
+	d := target.Subtract(t.pos)
+	dist := d.Magnitude()
+
+	// The time it takes to ramp up to full acceleration.
+	lag := 0.0
+	if t.Limits.Acceleration > 0 && t.Limits.Jerk > 0 {
+		lag = t.Limits.Acceleration / t.Limits.Jerk
+	}
+
+	// The fastest speed from which we can still stop at the target. The lag
+	// adds to the stopping distance of v²/2a, so brake a bit early. This was
+	// tuned experimentally, to avoid overshooting.
+	speed := limit(t.Limits.Velocity)
+	if a := t.Limits.Acceleration; a > 0 {
+		speed = math.Min(speed, a*(math.Sqrt((lag*lag)+(2*dist/a))-lag))
+	}
+
+	// Don't go past the target in a single tick.
+	var vt math3d.Vector3
+	if dist > 0 {
+		if speed*dt >= dist {
+			vt = d.MultiplyByScalar(1 / dt)
+		} else {
+			vt = d.Unit().MultiplyByScalar(speed)
+		}
+	}
+
+	// Change the velocity over the lag rather than all at once, since it can't
+	// change any faster than that anyway, and trying to makes it oscillate.
+	at := clamp(vt.Subtract(t.vel).MultiplyByScalar(1/math.Max(dt, lag/2)), t.Limits.Acceleration)
+
+	if t.Limits.Jerk > 0 {
+		t.acc = *t.acc.Add(clamp(at.Subtract(t.acc), t.Limits.Jerk*dt))
+	} else {
+		t.acc = at
+	}
+
+	t.vel = clamp(*t.vel.Add(t.acc.MultiplyByScalar(dt)), t.Limits.Velocity)
+	t.pos = *t.pos.Add(t.vel.MultiplyByScalar(dt))
+
+	return t.pos
+}
+
+// limit returns the given limit, or infinity if it's zero.
+func limit(l float64) float64 {
+	if l == 0 {
+		return math.Inf(1)
+	}
+	return l
+}
+
+// clamp returns the given vector, scaled down to the given magnitude if it's
+// longer than that. Zero means unlimited.
+func clamp(v math3d.Vector3, max float64) math3d.Vector3 {
+	if max > 0 && v.Magnitude() > max {
+		return v.Unit().MultiplyByScalar(max)
+	}
+	return v
+}
@@ -0,0 +1,52 @@
+package trajectory
+
+import (
+	"math"
+	"testing"
+
+	"github.com/adammck/hexapod/math3d"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUnlimited(t *testing.T) {
+	tr := New(Limits{})
+	target := math3d.Vector3{X: 100, Z: -50}
+	assert.Equal(t, target, tr.Update(target, 0.01))
+}
+
+func TestLimited(t *testing.T) {
+	l := Limits{Velocity: 100, Acceleration: 200, Jerk: 1000}
+
+	for _, fps := range []float64{30, 60, 120} {
+		dt := 1 / fps
+		tr := New(l)
+		target := math3d.Vector3{X: 300}
+
+		var prevV, prevA, maxX float64
+		arrived := 0.0
+		for i := 0; i < int(10*fps); i++ {
+			p := tr.Update(target, dt)
+			v := tr.Velocity().X
+			a := (v - prevV) / dt
+
+			assert.True(t, v <= l.Velocity+1e-6, "fps=%v velocity=%v", fps, v)
+			assert.True(t, a <= l.Acceleration+1e-6 && a >= -l.Acceleration-1e-6, "fps=%v accel=%v", fps, a)
+			assert.True(t, math.Abs(a-prevA)/dt <= l.Jerk+1e-6, "fps=%v jerk=%v", fps, (a-prevA)/dt)
+
+			if p.X > maxX {
+				maxX = p.X
+			}
+			if arrived == 0 && math.Abs(p.X-target.X) < 0.01 && math.Abs(v) < 1 {
+				arrived = float64(i) / fps
+			}
+
+			prevV = v
+			prevA = a
+		}
+
+		// At 100mm/s it takes at least 3s, plus a bit for speeding up and
+		// slowing down, which should be about the same at any frame rate.
+		assert.InDelta(t, 300, maxX, 0.5, "fps=%v", fps)
+		assert.InDelta(t, 4.4, arrived, 0.2, "fps=%v", fps)
+	}
+}
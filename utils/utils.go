@@ -14,6 +14,18 @@ func Rad(degrees float64) float64 {
 	return (math.Pi / 180) * degrees
 }
 
+// AngleDiff returns the difference (in degrees) between two angles, between
+// -180 and +180. Headings aren't wrapped, so this is the shortest way round.
+func AngleDiff(a, b float64) float64 {
+	d := math.Mod(a-b, 360)
+	if d > 180 {
+		d -= 360
+	} else if d < -180 {
+		d += 360
+	}
+	return d
+}
+
 type FrameCounter struct {
 	sync.Mutex
 